@@ -0,0 +1,48 @@
+package impact
+
+import (
+	"math"
+	"time"
+)
+
+// the number of background slots
+const hoursPerWeek = 7 * 24
+
+// how much data is needed before a background level is used, in seconds
+const backgroundMinimum = 3600.0
+
+// a background peak velocity for each hour of the week (UTC), learnt from recent data
+type Background struct {
+	Levels [hoursPerWeek]float64 `json:"levels"` // smoothed log peak velocity
+	Seen   [hoursPerWeek]float64 `json:"seen"`   // seconds of data learnt from
+}
+
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return (int)(t.Weekday())*24 + t.Hour()
+}
+
+// learn from the peak velocity of a block of data, the memory is how much data from each
+// hour slot is remembered, e.g. four weeks of history would be four hours
+func (b *Background) Update(t time.Time, pgv float64, length, memory time.Duration) {
+	if !(pgv > 0.0) || !(length > 0) || !(memory > 0) {
+		return
+	}
+
+	h := hourOfWeek(t)
+
+	// a running mean until the memory is full
+	a := length.Seconds() / math.Min(b.Seen[h]+length.Seconds(), memory.Seconds())
+
+	b.Levels[h] += a * (math.Log(pgv) - b.Levels[h])
+	b.Seen[h] += length.Seconds()
+}
+
+// the background peak velocity at the given time, if enough is known
+func (b *Background) Level(t time.Time) (float64, bool) {
+	h := hourOfWeek(t)
+	if b.Seen[h] < backgroundMinimum {
+		return 0.0, false
+	}
+	return math.Exp(b.Levels[h]), true
+}
@@ -0,0 +1,37 @@
+package impact
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackground(t *testing.T) {
+	var b Background
+
+	// a monday morning
+	busy := time.Date(2015, 8, 17, 9, 0, 0, 0, time.UTC)
+	// a sunday night
+	quiet := time.Date(2015, 8, 16, 2, 0, 0, 0, time.UTC)
+
+	if _, ok := b.Level(busy); ok {
+		t.Fatal("unexpected background level without data")
+	}
+
+	for i := 0; i < 720; i++ {
+		b.Update(busy.Add(time.Duration(i)*5*time.Second), 1.0e-4, 5*time.Second, 4*time.Hour)
+		b.Update(quiet.Add(time.Duration(i)*5*time.Second), 1.0e-6, 5*time.Second, 4*time.Hour)
+	}
+
+	level, ok := b.Level(busy.Add(7 * 24 * time.Hour))
+	if !ok || math.Abs(level-1.0e-4) > 1.0e-9 {
+		t.Errorf("invalid busy level: %g %v", level, ok)
+	}
+	level, ok = b.Level(quiet)
+	if !ok || math.Abs(level-1.0e-6) > 1.0e-11 {
+		t.Errorf("invalid quiet level: %g %v", level, ok)
+	}
+	if _, ok := b.Level(busy.Add(time.Hour)); ok {
+		t.Error("unexpected background level for an unseen hour")
+	}
+}
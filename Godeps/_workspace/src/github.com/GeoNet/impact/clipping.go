@@ -0,0 +1,31 @@
+package impact
+
+import (
+	"math"
+)
+
+// how close to full scale a sample needs to be to be taken as clipped
+const clipFraction = 0.98
+
+// how many consecutive samples near full scale make a clipped run
+const clipRun = 2
+
+// whether there is a run of samples at or near the digitiser full scale
+func Clipped(samples []int32, fullscale float64) bool {
+	if !(fullscale > 0.0) {
+		return false
+	}
+
+	var run int
+	for _, v := range samples {
+		if math.Abs((float64)(v)) < clipFraction*fullscale {
+			run = 0
+			continue
+		}
+		if run++; run >= clipRun {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,24 @@
+package impact
+
+import (
+	"testing"
+)
+
+func TestClipped(t *testing.T) {
+	var tests = []struct {
+		samples   []int32
+		fullscale float64
+		clipped   bool
+	}{
+		{[]int32{0, 100, -100, 8388607, 0}, 8388608, false},
+		{[]int32{0, 100, 8388607, 8388607, 0}, 8388608, true},
+		{[]int32{0, -8388608, -8388000, -100}, 8388608, true},
+		{[]int32{0, 8388607, 8388607, 0}, 0, false},
+	}
+
+	for i, x := range tests {
+		if c := Clipped(x.samples, x.fullscale); c != x.clipped {
+			t.Errorf("%d: invalid clipping: %v (found) != %v (expected)", i, c, x.clipped)
+		}
+	}
+}
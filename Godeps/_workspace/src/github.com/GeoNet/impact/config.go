@@ -0,0 +1,172 @@
+package impact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// a problem with a stream configuration entry
+type ConfigError struct {
+	Key     string // stream key, e.g. NZ_WEL_10_HHZ
+	Field   string // offending field, empty if the problem is with the key itself
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.Key, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Key, e.Field, e.Message)
+}
+
+// check a stream configuration entry, returning all the problems found
+func (s *Stream) Validate(srcname string) []error {
+	var errs []error
+
+	if !regexp.MustCompile(VELOCITY).MatchString(srcname) && !regexp.MustCompile(ACCELERATION).MatchString(srcname) {
+		errs = append(errs, &ConfigError{Key: srcname, Message: "unable to match srcname for velocity or acceleration"})
+	}
+	if !(s.Rate > 0.0) {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Rate", Message: fmt.Sprintf("must be positive: %g", s.Rate)})
+	}
+	if !(s.Gain > 0.0) {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Gain", Message: fmt.Sprintf("must be positive: %g", s.Gain)})
+	}
+	if !(s.Q > 0.0 && s.Q < 1.0) {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Q", Message: fmt.Sprintf("must be between 0 and 1: %g", s.Q)})
+	}
+	if s.Latitude < -90.0 || s.Latitude > 90.0 {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Latitude", Message: fmt.Sprintf("out of range: %g", s.Latitude)})
+	}
+	if s.Longitude < -180.0 || s.Longitude > 180.0 {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Longitude", Message: fmt.Sprintf("out of range: %g", s.Longitude)})
+	}
+	if s.Level < 0 {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Level", Message: fmt.Sprintf("must not be negative: %d", s.Level)})
+	}
+	if s.Probation < 0.0 {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Probation", Message: fmt.Sprintf("must not be negative: %g", s.Probation)})
+	}
+	if s.FlushInterval < 0.0 {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "FlushInterval", Message: fmt.Sprintf("must not be negative: %g", s.FlushInterval)})
+	}
+	if s.FullScale < 0.0 {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "FullScale", Message: fmt.Sprintf("must not be negative: %g", s.FullScale)})
+	}
+	if c := s.Checks; c != nil && (c.Flat < 0.0 || c.Spike < 0.0 || c.Offset < 0.0 || c.Mass < 0.0 || c.Timing < 0.0) {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Checks", Message: "thresholds must not be negative"})
+	}
+	if s.Baseline < 0.0 {
+		errs = append(errs, &ConfigError{Key: srcname, Field: "Baseline", Message: fmt.Sprintf("must not be negative: %g", s.Baseline)})
+	}
+	if d := s.Detector; d != nil {
+		for _, err := range d.Validate() {
+			errs = append(errs, &ConfigError{Key: srcname, Field: "Detector", Message: err.Error()})
+		}
+	}
+
+	return errs
+}
+
+// decode a json object of streams one entry at a time, so any problems can be tied to a stream key
+func decodeStreams(r io.Reader, add func(key string, s *Stream) error) error {
+	d := json.NewDecoder(r)
+	if t, err := d.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return errors.New("expected a json object of streams")
+	}
+
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return errors.New("expected a stream key")
+		}
+
+		var s Stream
+		if err := d.Decode(&s); err != nil {
+			if e, ok := err.(*json.UnmarshalTypeError); ok {
+				return &ConfigError{Key: key, Field: e.Field, Message: fmt.Sprintf("expected %s, got %s", e.Type, e.Value)}
+			}
+			return &ConfigError{Key: key, Message: err.Error()}
+		}
+
+		if err := add(key, &s); err != nil {
+			return err
+		}
+	}
+
+	if _, err := d.Token(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// read a json stream configuration
+func ReadStreams(r io.Reader) (map[string]*Stream, error) {
+	streams := make(map[string]*Stream)
+	err := decodeStreams(r, func(key string, s *Stream) error {
+		if _, ok := streams[key]; ok {
+			return &ConfigError{Key: key, Message: "duplicate stream"}
+		}
+		streams[key] = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return streams, nil
+}
+
+// read a json stream configuration file
+func LoadStreams(config string) (map[string]*Stream, error) {
+	f, err := os.Open(config)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadStreams(f)
+}
+
+// check a json stream configuration, returning all the problems found, including any stream
+// which is given more than once and would otherwise silently replace the earlier entry
+func ValidateConfig(r io.Reader) []error {
+	var errs []error
+
+	streams := make(map[string]*Stream)
+	err := decodeStreams(r, func(key string, s *Stream) error {
+		if _, ok := streams[key]; ok {
+			errs = append(errs, &ConfigError{Key: key, Message: "duplicate stream"})
+			return nil
+		}
+		streams[key] = s
+		return nil
+	})
+	if err != nil {
+		return append(errs, err)
+	}
+
+	keys := make([]string, 0, len(streams))
+	for k := range streams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		errs = append(errs, streams[k].Validate(k)...)
+	}
+
+	return errs
+}
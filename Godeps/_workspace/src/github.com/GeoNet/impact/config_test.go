@@ -0,0 +1,89 @@
+package impact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	config := `{
+		"NZ_WEL_10_HHZ": {"Name": "Wellington", "Latitude": -41.28, "Longitude": 174.77, "Rate": 100, "Gain": 1000, "Q": 0.98},
+		"NZ_WEL_20_HNZ": {"Name": "Wellington", "Latitude": -141.28, "Longitude": 174.77, "Rate": 0, "Gain": 1000, "Q": 0.98},
+		"NZ_WEL_10_LOG": {"Name": "Wellington", "Latitude": -41.28, "Longitude": 174.77, "Rate": 1, "Gain": 1, "Q": 1.5},
+		"NZ_WEL_10_HHZ": {"Name": "Wellington", "Latitude": -41.28, "Longitude": 174.77, "Rate": 100, "Gain": 1000, "Q": 0.98}
+	}`
+
+	expected := []ConfigError{
+		{Key: "NZ_WEL_10_HHZ"},
+		{Key: "NZ_WEL_10_LOG"},
+		{Key: "NZ_WEL_10_LOG", Field: "Q"},
+		{Key: "NZ_WEL_20_HNZ", Field: "Rate"},
+		{Key: "NZ_WEL_20_HNZ", Field: "Latitude"},
+	}
+
+	errs := ValidateConfig(strings.NewReader(config))
+	if len(errs) != len(expected) {
+		t.Fatalf("invalid number of errors: %d (found) != %d (expected): %v", len(errs), len(expected), errs)
+	}
+	for i, err := range errs {
+		e, ok := err.(*ConfigError)
+		if !ok {
+			t.Fatalf("unexpected error type: %s", err)
+		}
+		if e.Key != expected[i].Key || e.Field != expected[i].Field {
+			t.Errorf("invalid error: %s (found) != %s/%s (expected)", e, expected[i].Key, expected[i].Field)
+		}
+	}
+}
+
+func TestValidateConfigSyntax(t *testing.T) {
+	if errs := ValidateConfig(strings.NewReader(`{"NZ_WEL_10_HHZ": {`)); len(errs) != 1 {
+		t.Errorf("expected a single syntax error: %v", errs)
+	}
+}
+
+func TestReadStreams(t *testing.T) {
+	streams, err := ReadStreams(strings.NewReader(`{
+		"NZ_WEL_10_HHZ": {"Name": "Wellington", "Latitude": -41.28, "Longitude": 174.77, "Rate": 100, "Gain": 1000, "Q": 0.98},
+		"NZ_WEL_20_HNZ": {"Name": "Wellington", "Latitude": -41.28, "Longitude": 174.77, "Rate": 200, "Gain": 1000, "Q": 0.98}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 {
+		t.Fatalf("invalid number of streams: %d (found) != %d (expected)", len(streams), 2)
+	}
+	if s, ok := streams["NZ_WEL_20_HNZ"]; !ok || s.Rate != 200 {
+		t.Errorf("invalid stream: %v", s)
+	}
+}
+
+func TestReadStreamsErrors(t *testing.T) {
+	var tests = []struct {
+		config string
+		key    string
+		field  string
+	}{
+		{`{"NZ_WEL_10_HHZ": {"Rate": "fast"}}`, "NZ_WEL_10_HHZ", "Rate"},
+		{`{"NZ_WEL_10_HHZ": {"Rate": 100}, "NZ_WEL_10_HHZ": {"Rate": 100}}`, "NZ_WEL_10_HHZ", ""},
+	}
+
+	for _, x := range tests {
+		_, err := ReadStreams(strings.NewReader(x.config))
+		e, ok := err.(*ConfigError)
+		if !ok {
+			t.Errorf("expected a config error: %v", err)
+			continue
+		}
+		if e.Key != x.key || e.Field != x.field {
+			t.Errorf("invalid error: %s (found) != %s/%s (expected)", e, x.key, x.field)
+		}
+	}
+
+	if _, err := ReadStreams(strings.NewReader(`[]`)); err == nil {
+		t.Error("expected an error for a non object config")
+	}
+	if _, err := LoadStreams("missing.json"); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+}
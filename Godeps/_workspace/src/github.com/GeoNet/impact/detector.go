@@ -0,0 +1,124 @@
+package impact
+
+import (
+	"fmt"
+	"math"
+)
+
+// event detection settings, used to tell earthquake like transients from persistent noise
+type Detector struct {
+	Short    float64 // short term average window in seconds
+	Long     float64 // long term average window in seconds
+	On       float64 // ratio needed to trigger
+	Off      float64 // ratio needed to release a trigger
+	Kurtosis float64 // optional kurtosis needed over the short term window to trigger, zero to ignore
+}
+
+// check the detector settings, an invalid detector would otherwise be silently ignored
+func (d *Detector) Validate() []error {
+	var errs []error
+	if !(d.Short > 0.0 && d.Long > d.Short) {
+		errs = append(errs, fmt.Errorf("the long window must be longer than the short window: %g %g", d.Short, d.Long))
+	}
+	if !(d.On > d.Off && d.Off > 0.0) {
+		errs = append(errs, fmt.Errorf("the on ratio must be above the off ratio: %g %g", d.On, d.Off))
+	}
+	if d.Kurtosis < 0.0 {
+		errs = append(errs, fmt.Errorf("the kurtosis must not be negative: %g", d.Kurtosis))
+	}
+	return errs
+}
+
+// a recursive short term over long term average ratio detector
+type STALTA struct {
+	a, b     float64 // short and long term update coeffs
+	sta, lta float64 // running averages of the signal energy
+
+	n      int // samples in the long term window
+	warmup int // samples still needed before the ratio is usable
+}
+
+func NewSTALTA(short, long, rate float64) *STALTA {
+	d := new(STALTA)
+
+	d.a = 1.0 / (short * rate)
+	d.b = 1.0 / (long * rate)
+	d.n = (int)(long * rate)
+
+	d.Reset()
+
+	return d
+}
+
+func (d *STALTA) Reset() {
+	d.sta = 0.0
+	d.lta = 0.0
+	d.warmup = d.n
+}
+
+// add a sample, returning the current ratio which is zero until the long term window is full
+func (d *STALTA) Sample(x float64) float64 {
+	e := x * x
+
+	d.sta += d.a * (e - d.sta)
+	d.lta += d.b * (e - d.lta)
+
+	if d.warmup > 0 {
+		d.warmup--
+		return 0.0
+	}
+	if !(d.lta > 0.0) {
+		return 0.0
+	}
+
+	return d.sta / d.lta
+}
+
+// a sliding window kurtosis, impulsive signals have a higher kurtosis than random noise
+type Kurtosis struct {
+	window []float64 // recent samples
+	next   int       // the next sample slot
+	full   bool      // whether the window has been filled
+}
+
+func NewKurtosis(n int) *Kurtosis {
+	return &Kurtosis{window: make([]float64, n)}
+}
+
+func (k *Kurtosis) Reset() {
+	k.next = 0
+	k.full = false
+}
+
+// add a sample, returning the kurtosis of the window which is zero until it is full
+func (k *Kurtosis) Sample(x float64) float64 {
+	k.window[k.next] = x
+	k.next = (k.next + 1) % len(k.window)
+	if k.next == 0 {
+		k.full = true
+	}
+	if !k.full {
+		return 0.0
+	}
+
+	var mean float64
+	for _, v := range k.window {
+		mean += v
+	}
+	mean /= (float64)(len(k.window))
+
+	var m2, m4 float64
+	for _, v := range k.window {
+		d := (v - mean) * (v - mean)
+		m2 += d
+		m4 += d * d
+	}
+	m2 /= (float64)(len(k.window))
+	m4 /= (float64)(len(k.window))
+
+	if !(m2 > 0.0) {
+		return 0.0
+	}
+
+	return m4 / math.Pow(m2, 2.0)
+}
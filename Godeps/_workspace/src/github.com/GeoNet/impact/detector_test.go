@@ -0,0 +1,68 @@
+package impact
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSTALTA(t *testing.T) {
+	d := NewSTALTA(1.0, 10.0, 50.0)
+
+	// steady background noise
+	var ratio float64
+	for i := 0; i < 1000; i++ {
+		ratio = d.Sample(math.Sin((float64)(i)))
+	}
+	if ratio < 0.5 || ratio > 2.0 {
+		t.Errorf("invalid background ratio: %g", ratio)
+	}
+
+	// a sudden larger signal
+	for i := 0; i < 50; i++ {
+		ratio = d.Sample(10.0 * math.Sin((float64)(i)))
+	}
+	if ratio < 5.0 {
+		t.Errorf("invalid event ratio: %g", ratio)
+	}
+
+	d.Reset()
+	if ratio = d.Sample(1.0); ratio != 0.0 {
+		t.Errorf("expected no ratio after a reset: %g", ratio)
+	}
+}
+
+func TestKurtosis(t *testing.T) {
+	k := NewKurtosis(51)
+
+	var background float64
+	for i := 0; i < 51; i++ {
+		background = k.Sample(math.Sin((float64)(i)))
+	}
+
+	// a single spike
+	spike := k.Sample(20.0)
+
+	if !(spike > background) || spike < 10.0 {
+		t.Errorf("invalid spike kurtosis: %g (background %g)", spike, background)
+	}
+}
+
+func TestDetectorValidate(t *testing.T) {
+	var tests = []struct {
+		d    Detector
+		errs int
+	}{
+		{Detector{Short: 1.0, Long: 30.0, On: 4.0, Off: 1.5}, 0},
+		{Detector{Short: 1.0, Long: 30.0, On: 4.0, Off: 1.5, Kurtosis: 3.0}, 0},
+		{Detector{Short: 30.0, Long: 30.0, On: 4.0, Off: 1.5}, 1},
+		{Detector{Short: 1.0, Long: 30.0, On: 1.5, Off: 4.0}, 1},
+		{Detector{Short: 1.0, Long: 30.0, On: 4.0, Off: 0.0}, 1},
+		{Detector{Short: 60.0, Long: 30.0, On: 1.0, Off: 1.5, Kurtosis: -1.0}, 3},
+	}
+
+	for i, v := range tests {
+		if errs := v.d.Validate(); len(errs) != v.errs {
+			t.Errorf("%d: expected %d errors: %v", i, v.errs, errs)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package impact
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// the relative difference allowed between the configured and record sampling rates
+const rateTolerance = 0.001
+
+// the record sampling rate doesn't match the configured rate
+type RateError struct {
+	Expected float64 // the configured sampling rate
+	Found    float64 // the record sampling rate
+}
+
+func (e *RateError) Error() string {
+	return fmt.Sprintf("sampling rate mismatch: %g (found) != %g (expected)", e.Found, e.Expected)
+}
+
+// record header details which affect processing
+type Header struct {
+	Quality     byte // the data quality indicator, i.e. D, R, Q or M
+	Calibration bool // calibration signals present
+	Clipped     bool // amplifier saturation or digitiser clipping flagged
+	Glitches    bool // spikes or glitches flagged
+	Timing      bool // the time tag is questionable
+
+	TimingQuality int // blockette 1001 clock quality as a percentage, negative if not known
+
+	Rate float64 // the record sampling rate, zero if not known
+}
+
+// process a record, as for ProcessSamples, but also taking into account any header flags
+func (s *Stream) ProcessRecord(source string, srcname string, h Header, starttime time.Time, samples []int32) (Message, error) {
+
+	// filter coefficients depend on the sampling rate, the configured rate is kept for reloads
+	if rate := s.samplingRate(); h.Rate > 0.0 && math.Abs(h.Rate-rate) > rateTolerance*rate {
+		s.mismatches++
+		if !s.AdaptRate {
+			return Message{Source: source, Quality: "suspect", Reason: "rate"}, &RateError{Expected: rate, Found: h.Rate}
+		}
+
+		log.Printf("[%s] rebuilding filters for sampling rate: %g (found) != %g (expected)\n", srcname, h.Rate, rate)
+		s.rate = h.Rate
+		s.last = time.Time{}
+		if _, err := s.Init(srcname, s.probation, s.level); err != nil {
+			return Message{Source: source}, err
+		}
+	}
+
+	// calibration pulses aren't shaking, but the filters still need the samples
+	s.calibrating = h.Calibration
+	s.timing = h.TimingQuality
+
+	// the start time should already include any microsecond offset
+	m, err := s.ProcessSamples(source, srcname, starttime, samples)
+	if err != nil {
+		return m, err
+	}
+
+	// peak times are only as good as the clock
+	if c := s.Checks; c != nil && c.Timing > 0.0 && h.TimingQuality >= 0 && (float64)(h.TimingQuality) < c.Timing {
+		if s.problem == "" {
+			s.problem = "timing"
+		}
+		h.Timing = true
+	}
+
+	switch {
+	case h.Calibration:
+		m.Quality = "suspect"
+		m.Reason = "calibration"
+	case h.Clipped:
+		if m.Quality != "clipped" {
+			s.clips++
+		}
+		m.Quality = "clipped"
+		m.Bound = "lower"
+	case h.Glitches:
+		m.Quality = "suspect"
+		m.Reason = "glitches"
+	case h.Timing:
+		m.Quality = "suspect"
+		m.Reason = "timing"
+	case h.Quality == 'Q':
+		m.Quality = "suspect"
+		m.Reason = "questionable"
+	}
+
+	return m, nil
+}
@@ -0,0 +1,122 @@
+package impact
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProcessRecord(t *testing.T) {
+	var tests = []struct {
+		header  Header
+		quality string
+		reason  string
+		flush   bool
+	}{
+		{Header{Quality: 'D'}, "measured", "", true},
+		{Header{Quality: 'D', Calibration: true}, "suspect", "calibration", false},
+		{Header{Quality: 'D', Clipped: true}, "clipped", "", true},
+		{Header{Quality: 'D', Glitches: true}, "suspect", "glitches", true},
+		{Header{Quality: 'D', Timing: true}, "suspect", "timing", true},
+		{Header{Quality: 'Q'}, "suspect", "questionable", true},
+	}
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	for i, x := range tests {
+		s := testStream(t)
+		m, err := s.ProcessRecord("NZ.TEST", "NZ_TEST_10_HHZ", x.header, start, testSamples())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Quality != x.quality || m.Reason != x.reason {
+			t.Errorf("%d: invalid quality: %s/%s (found) != %s/%s (expected)", i, m.Quality, m.Reason, x.quality, x.reason)
+		}
+		if f := s.Flush(time.Second, m.MMI); f != x.flush {
+			t.Errorf("%d: invalid flush: %v (found) != %v (expected)", i, f, x.flush)
+		}
+	}
+}
+
+func TestProcessRecordTiming(t *testing.T) {
+	var tests = []struct {
+		checks  Checks
+		timing  int
+		quality string
+		flush   bool
+	}{
+		{Checks{}, 10, "measured", true},
+		{Checks{Timing: 50.0}, -1, "measured", true},
+		{Checks{Timing: 50.0}, 90, "measured", true},
+		{Checks{Timing: 50.0}, 30, "suspect", true},
+		{Checks{Timing: 50.0, Suppress: true}, 30, "suspect", false},
+	}
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	for i, x := range tests {
+		s := testStream(t)
+		s.Checks = &x.checks
+
+		m, err := s.ProcessRecord("NZ.TEST", "NZ_TEST_10_HHZ", Header{Quality: 'D', TimingQuality: x.timing}, start, testSamples())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Quality != x.quality {
+			t.Errorf("%d: invalid quality: %s (found) != %s (expected)", i, m.Quality, x.quality)
+		}
+		if s.Status().Timing != x.timing {
+			t.Errorf("%d: invalid timing: %d (found) != %d (expected)", i, s.Status().Timing, x.timing)
+		}
+		if f := s.Flush(time.Second, m.MMI); f != x.flush {
+			t.Errorf("%d: invalid flush: %v (found) != %v (expected)", i, f, x.flush)
+		}
+	}
+}
+
+func TestProcessRecordRate(t *testing.T) {
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+
+	// a small difference is expected
+	s := testStream(t)
+	if _, err := s.ProcessRecord("NZ.TEST", "NZ_TEST_10_HHZ", Header{Rate: 49.99999}, start, testSamples()); err != nil {
+		t.Fatal(err)
+	}
+
+	// but a different rate is rejected
+	_, err := s.ProcessRecord("NZ.TEST", "NZ_TEST_10_HHZ", Header{Rate: 100.0}, start, testSamples())
+	if e, ok := err.(*RateError); !ok || e.Expected != 50.0 || e.Found != 100.0 {
+		t.Errorf("expected a rate error: %v", err)
+	}
+	if status := s.Status(); status.Mismatches != 1 || status.Rate != 50.0 {
+		t.Errorf("invalid rate status: %g %d", status.Rate, status.Mismatches)
+	}
+
+	// unless the filters can be rebuilt
+	s.AdaptRate = true
+	if _, err := s.ProcessRecord("NZ.TEST", "NZ_TEST_10_HHZ", Header{Rate: 100.0}, start, testSamples()); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.Status(); status.Mismatches != 2 || status.Rate != 100.0 {
+		t.Errorf("invalid rate status: %g %d", status.Rate, status.Mismatches)
+	}
+
+	// the configured rate is kept, so an unchanged reload keeps the adapted filters
+	if s.Rate != 50.0 {
+		t.Errorf("configured rate changed: %g", s.Rate)
+	}
+	if changed, err := s.Update("NZ_TEST_10_HHZ", &Stream{Name: s.Name, Rate: 50.0, Gain: s.Gain, Q: s.Q, AdaptRate: true}); err != nil || changed {
+		t.Errorf("unexpected filter rebuild: %v %v", changed, err)
+	}
+	if _, err := s.ProcessRecord("NZ.TEST", "NZ_TEST_10_HHZ", Header{Rate: 100.0}, start.Add(2*time.Second), testSamples()); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.Status(); status.Mismatches != 2 || status.Rate != 100.0 {
+		t.Errorf("invalid rate status after reload: %g %d", status.Rate, status.Mismatches)
+	}
+
+	// but not once adapting is turned off
+	if changed, err := s.Update("NZ_TEST_10_HHZ", &Stream{Name: s.Name, Rate: 50.0, Gain: s.Gain, Q: s.Q}); err != nil || !changed {
+		t.Errorf("expected a filter rebuild: %v %v", changed, err)
+	}
+	if status := s.Status(); status.Rate != 50.0 {
+		t.Errorf("invalid rate status without adapting: %g", status.Rate)
+	}
+}
@@ -12,4 +12,8 @@ type Message struct {
 	Time      time.Time `json:"time"`
 	MMI       int32     `json:"MMI"`
 	Comment   string    `json:"comment"`
+	Reason    string    `json:"reason,omitempty"`   // why a status message was sent
+	Duration  float64   `json:"duration,omitempty"` // seconds spent noisy before jailing, or jailed before release
+	Ratio     float64   `json:"ratio,omitempty"`    // peak velocity over the usual background for the time of week
+	Bound     string    `json:"bound,omitempty"`    // "lower" if the intensity is only a lower bound, e.g. when clipped
 }
@@ -0,0 +1,85 @@
+package impact
+
+import (
+	"math"
+)
+
+// the window for the running mean used by the offset and mass centering checks, in seconds
+const offsetWindow = 60.0
+
+// data quality check settings, a zero value disables that check
+type Checks struct {
+	Flat     float64 // seconds of unchanging counts for a dead or flat-lined channel
+	Spike    float64 // ratio of a single sample step to the typical step for a spike
+	Offset   float64 // largest running mean in counts before the DC offset is a problem
+	Mass     float64 // largest running mean as a fraction of full scale before the mass is off centre
+	Timing   float64 // lowest blockette 1001 timing quality percentage before the clock is a problem
+	Suppress bool    // suppress messages with a problem rather than flagging them
+}
+
+// the running data quality state
+type checker struct {
+	flat   int     // consecutive unchanging samples
+	last   int32   // previous sample
+	mean   float64 // running mean
+	primed bool    // whether the running state has been started
+}
+
+func (c *checker) reset() {
+	c.flat = 0
+	c.primed = false
+}
+
+// check a block of samples, returning the reason for any problem found
+func (c *checker) check(checks *Checks, samples []int32, rate, fullscale float64) string {
+	if !(len(samples) > 0) {
+		return ""
+	}
+	if !c.primed {
+		c.last = samples[0]
+		c.mean = (float64)(samples[0])
+		c.primed = true
+	}
+
+	a := 1.0 / (offsetWindow * rate)
+
+	var steps float64
+	for _, v := range samples {
+		if v == c.last {
+			c.flat++
+		} else {
+			c.flat = 0
+		}
+		steps += math.Abs((float64)(v - c.last))
+		c.last = v
+
+		c.mean += a * ((float64)(v) - c.mean)
+	}
+
+	switch {
+	case checks.Flat > 0.0 && (float64)(c.flat) >= checks.Flat*rate:
+		return "flat"
+	case checks.Spike > 0.0 && spiky(samples, checks.Spike*steps/(float64)(len(samples))):
+		return "spiky"
+	case checks.Mass > 0.0 && fullscale > 0.0 && math.Abs(c.mean) > checks.Mass*fullscale:
+		return "mass"
+	case checks.Offset > 0.0 && math.Abs(c.mean) > checks.Offset:
+		return "offset"
+	}
+
+	return ""
+}
+
+// whether a single sample stands out from both its neighbours by more than the limit
+func spiky(samples []int32, limit float64) bool {
+	if !(limit > 0.0) {
+		return false
+	}
+	for i := 1; i < len(samples)-1; i++ {
+		mid := ((float64)(samples[i-1]) + (float64)(samples[i+1])) / 2.0
+		if math.Abs((float64)(samples[i])-mid) > limit && math.Abs((float64)(samples[i+1]-samples[i-1])) < limit {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package impact
+
+import (
+	"math"
+	"testing"
+)
+
+func TestChecker(t *testing.T) {
+	wave := func(n int, offset, amplitude float64) []int32 {
+		samples := make([]int32, n)
+		for i := range samples {
+			samples[i] = (int32)(offset + amplitude*math.Sin((float64)(i)/5.0))
+		}
+		return samples
+	}
+	flat := make([]int32, 50)
+	spike := wave(50, 0.0, 100.0)
+	spike[20] += 100000
+
+	var tests = []struct {
+		checks  Checks
+		samples []int32
+		reason  string
+	}{
+		{Checks{Flat: 1.0, Spike: 20.0, Offset: 1000.0, Mass: 0.5}, wave(50, 0.0, 100.0), ""},
+		{Checks{Flat: 1.0}, flat, "flat"},
+		{Checks{Flat: 2.0}, flat, ""},
+		{Checks{Spike: 20.0}, spike, "spiky"},
+		{Checks{Offset: 1000.0}, wave(3000, 5000.0, 100.0), "offset"},
+		{Checks{Mass: 0.5}, wave(3000, 5000.0, 100.0), "mass"},
+		{Checks{Mass: 0.5}, wave(3000, 4000.0, 100.0), ""},
+	}
+
+	for i, x := range tests {
+		var c checker
+		if r := c.check(&x.checks, x.samples, 50.0, 8000.0); r != x.reason {
+			t.Errorf("%d: invalid reason: \"%s\" (found) != \"%s\" (expected)", i, r, x.reason)
+		}
+	}
+}
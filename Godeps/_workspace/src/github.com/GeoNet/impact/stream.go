@@ -1,9 +1,7 @@
 package impact
 
 import (
-	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"log"
 	"math"
 	"regexp"
@@ -25,37 +23,91 @@ type Stream struct {
 	Gain      float64 // stream gain
 	Q         float64 // high-pass filter coeff
 
+	Level         int32   // noise threshold level, zero uses the default
+	Probation     float64 // noise probation period in seconds, zero uses the default
+	FlushInterval float64 // heartbeat flush interval in seconds, zero uses the default
+
+	Detector *Detector // optional event detection, so an earthquake isn't taken as noise
+	Baseline float64   // weeks of history for an hour of week background level, zero to disable
+
+	FullScale float64 // digitiser full scale in counts, zero to disable clipping checks
+	Checks    *Checks // optional data quality checks
+	AdaptRate bool    // rebuild the filters for a different record sampling rate rather than rejecting the data
+
 	h *HighPass   // high-pass filter
 	i *Integrator // intergrator
+	d *STALTA     // event detector
+	k *Kurtosis   // event kurtosis check
+	b *Background // hour of week background
+	c checker     // data quality state
+
+	problem     string // any data quality problem with the last packet
+	calibrating bool   // the last record holds calibration signals
+	timing      int    // the last record timing quality, negative if not known
+
+	triggered bool // the event detector is triggered
+	event     bool // the detector was triggered during the last packet
 
 	mmi   int32     // the last intesity sent
 	flush time.Time // previous flush
 	last  time.Time // previous packet
 
-	level     int32         // the noise threshold level
-	probation time.Duration // the noise probation period
+	level     int32         // the default noise threshold level
+	probation time.Duration // the default noise probation period
 
 	jailed bool      // it's been too noisy
 	good   time.Time // the last good data time
 	bad    time.Time // the last bad data time
+	since  time.Time // when it was jailed
+	change bool      // jailed or released by the last flush
+
+	override *Override // manually jailed or released
+
+	restored bool // noise state recovered from a snapshot, kept over the first reset
+
+	resets   int64 // filter resets after a break
+	clips    int64 // packets with clipped samples
+	gaps     int64 // breaks with missing data
+	overlaps int64 // breaks with repeated data
+
+	mismatches int64   // records with a different sampling rate
+	rate       float64 // the record sampling rate when adapted, otherwise zero to use the configured rate
 }
 
-// pull in public stream information from a json config file
-func LoadStreams(config string) map[string]*Stream {
-	f, err := ioutil.ReadFile(config)
-	if err != nil {
-		log.Printf("Could not load config file: \"%s\"\n", config)
-		log.Fatal(err)
-	}
+// a snapshot of the running stream state
+type Status struct {
+	Last       time.Time `json:"last"`               // end of the previous packet
+	MMI        int32     `json:"mmi"`                // the last intensity sent
+	Jailed     bool      `json:"jailed"`             // it's been too noisy
+	Good       time.Time `json:"good"`               // the last good data time
+	Bad        time.Time `json:"bad"`                // the last bad data time
+	Resets     int64     `json:"resets"`             // filter resets after a break
+	Clips      int64     `json:"clips"`              // packets with clipped samples
+	Problem    string    `json:"problem,omitempty"`  // any data quality problem with the last packet
+	Timing     int       `json:"timing"`             // the last record timing quality, negative if not known
+	Rate       float64   `json:"rate"`               // the sampling rate in use
+	Mismatches int64     `json:"mismatches"`         // records with a different sampling rate
+	Gaps       int64     `json:"gaps"`               // breaks with missing data
+	Overlaps   int64     `json:"overlaps"`           // breaks with repeated data
+	Event      bool      `json:"event"`              // an event was detected in the last packet
+	Override   *Override `json:"override,omitempty"` // any manual jail or release
+}
 
-	var s map[string]*Stream
-	err = json.Unmarshal(f, &s)
-	if err != nil {
-		log.Printf("Could not parse config file: \"%s\"\n", config)
-		log.Fatal(err)
-	}
+// the noise state of a stream which can be kept across restarts
+type Snapshot struct {
+	MMI    int32     `json:"mmi"`    // the last intensity sent
+	Jailed bool      `json:"jailed"` // it's been too noisy
+	Good   time.Time `json:"good"`   // the last good data time
+	Bad    time.Time `json:"bad"`    // the last bad data time
 
-	return s
+	Override   *Override   `json:"override,omitempty"`   // any manual jail or release
+	Background *Background `json:"background,omitempty"` // any learnt background levels
+}
+
+// a manual jail or release of a stream which takes precedence over the noise checks
+type Override struct {
+	Jailed bool      `json:"jailed"` // whether the stream is jailed or released
+	Until  time.Time `json:"until"`  // when the noise checks take over again
 }
 
 // initialize a stream, setting type of input and filters
@@ -63,9 +115,12 @@ func (s *Stream) Init(srcname string, probation time.Duration, level int32) (boo
 
 	s.probation = probation
 	s.level = level
+	s.timing = -1
 
 	s.h = nil
 	s.i = nil
+	s.d = nil
+	s.k = nil
 
 	// update structure and filters
 	if regexp.MustCompile(VELOCITY).MatchString(srcname) {
@@ -75,18 +130,190 @@ func (s *Stream) Init(srcname string, probation time.Duration, level int32) (boo
 	} else if regexp.MustCompile(ACCELERATION).MatchString(srcname) {
 		if s.Q > 0.0 {
 			s.h = NewHighPass(s.Gain, s.Q)
-			s.i = NewIntegrator(1.0, 1.0/s.Rate, s.Q)
+			s.i = NewIntegrator(1.0, 1.0/s.samplingRate(), s.Q)
 		}
 	} else {
 		return false, errors.New("unable to match srcname for velocity or acceleration")
 	}
 
+	// keep any learnt background levels
+	if s.Baseline > 0.0 {
+		if s.b == nil {
+			s.b = &Background{}
+		}
+	} else {
+		s.b = nil
+	}
+
+	// optional event detection
+	if d := s.Detector; d != nil && s.samplingRate() > 0.0 && d.Short > 0.0 && d.Long > d.Short {
+		s.d = NewSTALTA(d.Short, d.Long, s.samplingRate())
+		if d.Kurtosis > 0.0 {
+			s.k = NewKurtosis((int)(d.Short*s.samplingRate()) + 1)
+		}
+	}
+
 	return true, nil
 }
 
-// time to send a message, either timeout or different value
+// update the configuration of a running stream, the filters are rebuilt if the sampling
+// rate, gain or filter coefficient have changed otherwise the running state is kept
+func (s *Stream) Update(srcname string, c *Stream) (bool, error) {
+
+	s.Name = c.Name
+	s.Latitude = c.Latitude
+	s.Longitude = c.Longitude
+
+	s.Level = c.Level
+	s.Probation = c.Probation
+	s.FlushInterval = c.FlushInterval
+	s.Baseline = c.Baseline
+	s.FullScale = c.FullScale
+	s.Checks = c.Checks
+	s.AdaptRate = c.AdaptRate
+	if s.Baseline > 0.0 && s.b == nil {
+		s.b = &Background{}
+	} else if !(s.Baseline > 0.0) {
+		s.b = nil
+	}
+
+	// an adapted sampling rate is kept unless no longer allowed
+	adapted := s.rate > 0.0 && !s.AdaptRate
+	if !adapted && s.Rate == c.Rate && s.Gain == c.Gain && s.Q == c.Q && sameDetector(s.Detector, c.Detector) {
+		return false, nil
+	}
+
+	s.rate = 0.0
+	s.Rate = c.Rate
+	s.Gain = c.Gain
+	s.Q = c.Q
+	s.Detector = c.Detector
+
+	// force a filter reset with the next packet
+	s.last = time.Time{}
+
+	return s.Init(srcname, s.probation, s.level)
+}
+
+// the sampling rate in use, either the configured rate or one adapted to the records
+func (s *Stream) samplingRate() float64 {
+	if s.rate > 0.0 {
+		return s.rate
+	}
+	return s.Rate
+}
+
+func sameDetector(a, b *Detector) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// the noise threshold level, either configured or the default, raised to the
+// background level for the time of week if this is higher
+func (s *Stream) NoiseLevel() int32 {
+	level := s.level
+	if s.Level > 0 {
+		level = s.Level
+	}
+	if s.b != nil {
+		if v, ok := s.b.Level(s.last); ok && Intensity(v) > level {
+			level = Intensity(v)
+		}
+	}
+	return level
+}
+
+// the noise probation period, either configured or the default
+func (s *Stream) NoiseProbation() time.Duration {
+	if s.Probation > 0.0 {
+		return (time.Duration)(s.Probation * (float64)(time.Second))
+	}
+	return s.probation
+}
+
+// the last intensity flushed
+func (s *Stream) MMI() int32 {
+	return s.mmi
+}
+
+// the current running state
+func (s *Stream) Status() Status {
+	return Status{
+		Last:       s.last,
+		MMI:        s.mmi,
+		Jailed:     s.jailed,
+		Good:       s.good,
+		Bad:        s.bad,
+		Resets:     s.resets,
+		Clips:      s.clips,
+		Problem:    s.problem,
+		Timing:     s.timing,
+		Rate:       s.samplingRate(),
+		Mismatches: s.mismatches,
+		Gaps:       s.gaps,
+		Overlaps:   s.overlaps,
+		Event:      s.event,
+		Override:   s.override,
+	}
+}
+
+// the current noise state
+func (s *Stream) Snapshot() Snapshot {
+	snapshot := Snapshot{
+		MMI:    s.mmi,
+		Jailed: s.jailed,
+		Good:   s.good,
+		Bad:    s.bad,
+
+		Override: s.override,
+	}
+
+	// a copy, as the background keeps learning
+	if s.b != nil {
+		b := *s.b
+		snapshot.Background = &b
+	}
+
+	return snapshot
+}
+
+// recover a saved noise state, this is kept when the filters are reset by the first packet
+func (s *Stream) Restore(snapshot Snapshot) {
+	s.mmi = snapshot.MMI
+	s.jailed = snapshot.Jailed
+	s.good = snapshot.Good
+	s.bad = snapshot.Bad
+
+	if b := snapshot.Background; b != nil && s.b != nil {
+		*s.b = *b
+	}
+
+	if o := snapshot.Override; o != nil && time.Now().Before(o.Until) {
+		s.override = o
+		s.jailed = o.Jailed
+	}
+
+	s.restored = true
+}
+
+// time to send a message, either timeout or different value, a configured
+// flush interval takes precedence over the given default
 func (s *Stream) Flush(d time.Duration, mmi int32) bool {
 
+	// bad data shouldn't change anything
+	if s.problem != "" && s.Checks != nil && s.Checks.Suppress {
+		return false
+	}
+	if s.calibrating {
+		return false
+	}
+
+	if s.FlushInterval > 0.0 {
+		d = (time.Duration)(s.FlushInterval * (float64)(time.Second))
+	}
+
 	// same intensity?
 	if s.mmi == mmi {
 		// ignore times
@@ -103,20 +330,38 @@ func (s *Stream) Flush(d time.Duration, mmi int32) bool {
 	s.flush = time.Now()
 	s.mmi = mmi
 
-	// a noisy stream
-	if s.mmi > s.level {
+	jailed := s.jailed
+
+	// a noisy stream, unless it looks like an earthquake
+	if s.mmi > s.NoiseLevel() && !s.event {
 		// should be jailed ...
-		if s.last.Sub(s.good) > s.probation {
+		if s.last.Sub(s.good) > s.NoiseProbation() {
 			s.jailed = true
 		}
 		s.bad = s.last
 	} else {
-		if s.last.Sub(s.bad) > s.probation {
+		if s.last.Sub(s.bad) > s.NoiseProbation() {
 			s.jailed = false
 		}
 		s.good = s.last
 	}
 
+	// a manual override takes precedence until it expires
+	if o := s.override; o != nil {
+		if time.Now().Before(o.Until) {
+			s.jailed = o.Jailed
+		} else {
+			s.override = nil
+		}
+	}
+
+	if s.jailed != jailed {
+		s.change = true
+		if s.jailed {
+			s.since = s.last
+		}
+	}
+
 	// skip as noisy
 	if s.jailed {
 		return false
@@ -125,6 +370,82 @@ func (s *Stream) Flush(d time.Duration, mmi int32) bool {
 	return true
 }
 
+// manually jail or release the stream until the override expires, a nil override returns the
+// stream to the noise checks
+func (s *Stream) SetOverride(o *Override) {
+	s.override = o
+	if o == nil || s.jailed == o.Jailed {
+		return
+	}
+
+	s.jailed = o.Jailed
+	s.change = true
+	if s.jailed {
+		s.since = s.last
+	}
+}
+
+// update the event trigger state with a velocity sample
+func (s *Stream) detect(f float64) {
+	ratio := s.d.Sample(f)
+
+	// an optional check for impulsive signals
+	impulsive := true
+	if s.k != nil {
+		impulsive = s.k.Sample(f) >= s.Detector.Kurtosis
+	}
+
+	switch {
+	case !s.triggered && ratio > s.Detector.On && impulsive:
+		s.triggered = true
+	case s.triggered && ratio < s.Detector.Off:
+		s.triggered = false
+	}
+
+	if s.triggered {
+		s.event = true
+	}
+}
+
+// a status message if the last flush jailed or released the stream, based on the given message
+func (s *Stream) JailMessage(m Message) (Message, bool) {
+	if !s.change {
+		return m, false
+	}
+	s.change = false
+
+	// not the data message details
+	m.MMI = s.mmi
+	m.Time = s.last
+	m.Bound = ""
+	m.Ratio = 0.0
+	m.Duration = 0.0
+
+	if o := s.override; o != nil {
+		if o.Jailed {
+			m.Quality = "suspect"
+		} else {
+			m.Quality = "measured"
+		}
+		m.Reason = "manual"
+		m.Duration = o.Until.Sub(time.Now()).Seconds()
+	} else if s.jailed {
+		m.Quality = "suspect"
+		m.Reason = "noisy"
+		if s.good.After(time.Unix(0, 0)) {
+			m.Duration = s.last.Sub(s.good).Seconds()
+		}
+	} else {
+		m.Quality = "measured"
+		m.Reason = "released"
+		if !s.since.IsZero() {
+			m.Duration = s.last.Sub(s.since).Seconds()
+		}
+	}
+
+	return m, true
+}
+
 // given an array of samples .. pass them through a block at a time
 func (s *Stream) ProcessSamples(source string, srcname string, starttime time.Time, samples []int32) (Message, error) {
 
@@ -132,7 +453,7 @@ func (s *Stream) ProcessSamples(source string, srcname string, starttime time.Ti
 	m := Message{Source: source, Quality: "measured", Latitude: s.Latitude, Longitude: s.Longitude, Comment: s.Name}
 
 	// need a sampling rate
-	if !(s.Rate > 0.0) {
+	if !(s.samplingRate() > 0.0) {
 		return m, errors.New("invalid sampling rate")
 	}
 	// check we have samples
@@ -145,8 +466,18 @@ func (s *Stream) ProcessSamples(source string, srcname string, starttime time.Ti
 	}
 
 	// has there been a break?
-	if math.Abs(starttime.Sub(s.last).Seconds()-1.0/s.Rate) > (0.5 / s.Rate) {
+	if diff := starttime.Sub(s.last).Seconds() - 1.0/s.samplingRate(); math.Abs(diff) > (0.5 / s.samplingRate()) {
 		log.Printf("[%s] reset stream: %s\n", srcname, starttime)
+		s.resets++
+
+		// a running stream has either missing or repeated data
+		if !s.last.IsZero() {
+			if diff > 0.0 {
+				s.gaps++
+			} else {
+				s.overlaps++
+			}
+		}
 
 		// reset filters
 		if s.h != nil {
@@ -155,6 +486,14 @@ func (s *Stream) ProcessSamples(source string, srcname string, starttime time.Ti
 		if s.i != nil {
 			s.i.Reset()
 		}
+		if s.d != nil {
+			s.d.Reset()
+		}
+		if s.k != nil {
+			s.k.Reset()
+		}
+		s.triggered = false
+		s.c.reset()
 
 		// first run it backwards (a pre-conditioning strategy)
 		for i := range samples {
@@ -165,12 +504,16 @@ func (s *Stream) ProcessSamples(source string, srcname string, starttime time.Ti
 			}
 		}
 
-		// reset the noise times
-		s.bad = time.Unix(0, 0)
-		s.good = time.Unix(0, 0)
+		// reset the noise times, unless just restored
+		if !s.restored {
+			s.bad = time.Unix(0, 0)
+			s.good = time.Unix(0, 0)
+		}
+		s.restored = false
 	}
 
 	// reset time
+	s.event = false
 	m.Time = starttime
 	m.MMI = Intensity(0)
 
@@ -186,15 +529,47 @@ func (s *Stream) ProcessSamples(source string, srcname string, starttime time.Ti
 			f = (float64)(samples[i]) / s.Gain
 		}
 
+		if s.d != nil {
+			s.detect(f)
+		}
+
 		if math.Abs(f) > max {
 			max = math.Abs(f)
-			m.Time = starttime.Add((time.Duration)((float64)(time.Second) * (float64)(i) / s.Rate))
+			m.Time = starttime.Add((time.Duration)((float64)(time.Second) * (float64)(i) / s.samplingRate()))
 			m.MMI = Intensity(max)
 		}
 	}
 
+	// look for dead or misbehaving sensors
+	s.problem = ""
+	if s.Checks != nil {
+		if s.problem = s.c.check(s.Checks, samples, s.samplingRate(), s.FullScale); s.problem != "" {
+			m.Quality = "suspect"
+			m.Reason = s.problem
+		}
+	}
+
+	// the velocity and so the intensity will be underestimated
+	clipped := Clipped(samples, s.FullScale)
+	if clipped {
+		s.clips++
+		m.Quality = "clipped"
+		m.Bound = "lower"
+	}
+
+	// compare with and learn the usual background, but not from earthquakes or noise
+	if s.b != nil {
+		if v, ok := s.b.Level(starttime); ok && v > 0.0 {
+			m.Ratio = max / v
+		}
+		if !s.event && !s.jailed && !clipped && s.problem == "" && !s.calibrating {
+			length := (time.Duration)((float64)(time.Second) * (float64)(len(samples)) / s.samplingRate())
+			s.b.Update(starttime, max, length, (time.Duration)(s.Baseline*(float64)(time.Hour)))
+		}
+	}
+
 	// get ready for next packet
-	s.last = starttime.Add((time.Duration)((float64)(time.Second) * (float64)(len(samples)-1) / s.Rate))
+	s.last = starttime.Add((time.Duration)((float64)(time.Second) * (float64)(len(samples)-1) / s.samplingRate()))
 
 	return m, nil
 }
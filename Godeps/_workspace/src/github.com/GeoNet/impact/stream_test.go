@@ -0,0 +1,352 @@
+package impact
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// a stream ready for processing
+func testStream(t *testing.T) *Stream {
+	s := &Stream{Name: "test", Rate: 50.0, Gain: 427336.1, Q: 0.95395}
+	if _, err := s.Init("NZ_TEST_10_HHZ", 10*time.Minute, 2); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// the test samples as a packet
+func testSamples() []int32 {
+	samples := make([]int32, len(TestSlice))
+	for i := range TestSlice {
+		samples[i] = TestSlice[i].i
+	}
+	return samples
+}
+
+func TestStreamBreaks(t *testing.T) {
+	s := testStream(t)
+
+	samples := testSamples()
+	length := time.Duration(len(samples)) * time.Second / 50
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, length, 2 * length, 4 * length, 4*length + length/2} {
+		if _, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start.Add(offset), samples); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status := s.Status()
+	if status.Resets != 3 {
+		t.Errorf("invalid resets: %d (found) != %d (expected)", status.Resets, 3)
+	}
+	if status.Gaps != 1 {
+		t.Errorf("invalid gaps: %d (found) != %d (expected)", status.Gaps, 1)
+	}
+	if status.Overlaps != 1 {
+		t.Errorf("invalid overlaps: %d (found) != %d (expected)", status.Overlaps, 1)
+	}
+}
+
+func TestStreamUpdate(t *testing.T) {
+	s := testStream(t)
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	if _, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start, testSamples()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a location change keeps the running state
+	if changed, err := s.Update("NZ_TEST_10_HHZ", &Stream{Name: "moved", Latitude: -41.0, Longitude: 174.0, Rate: s.Rate, Gain: s.Gain, Q: s.Q}); err != nil || changed {
+		t.Fatalf("unexpected update: %v %v", changed, err)
+	}
+	if s.Name != "moved" || s.Latitude != -41.0 || s.Longitude != 174.0 {
+		t.Errorf("stream details not updated: %s %g %g", s.Name, s.Latitude, s.Longitude)
+	}
+	if s.Status().Last.IsZero() {
+		t.Error("running state not kept")
+	}
+
+	// a gain change resets the filters
+	if changed, err := s.Update("NZ_TEST_10_HHZ", &Stream{Name: "moved", Rate: s.Rate, Gain: 2.0 * s.Gain, Q: s.Q}); err != nil || !changed {
+		t.Fatalf("unexpected update: %v %v", changed, err)
+	}
+	if !s.Status().Last.IsZero() {
+		t.Error("running state not reset")
+	}
+}
+
+func TestStreamOverrides(t *testing.T) {
+	s := testStream(t)
+
+	if s.NoiseLevel() != 2 || s.NoiseProbation() != 10*time.Minute {
+		t.Errorf("invalid noise defaults: %d %s", s.NoiseLevel(), s.NoiseProbation())
+	}
+
+	s.Level, s.Probation = 5, 60.0
+	if s.NoiseLevel() != 5 || s.NoiseProbation() != time.Minute {
+		t.Errorf("invalid noise overrides: %d %s", s.NoiseLevel(), s.NoiseProbation())
+	}
+
+	// a zero default never sends heartbeats
+	if s.Flush(0, 0) {
+		t.Error("unexpected flush without an interval")
+	}
+
+	s.FlushInterval = 60.0
+	if !s.Flush(0, 0) {
+		t.Error("expected a flush with a configured interval")
+	}
+	if s.Flush(0, 0) {
+		t.Error("unexpected flush within the configured interval")
+	}
+}
+
+func TestStreamRestore(t *testing.T) {
+	s := testStream(t)
+
+	bad := time.Date(2015, 8, 16, 23, 55, 0, 0, time.UTC)
+	s.Restore(Snapshot{MMI: 4, Jailed: true, Good: time.Unix(0, 0), Bad: bad})
+
+	// the first packet resets the filters but keeps the restored noise state
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	if _, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start, testSamples()); err != nil {
+		t.Fatal(err)
+	}
+
+	status := s.Status()
+	if !status.Jailed || status.MMI != 4 || !status.Bad.Equal(bad) {
+		t.Errorf("restored state not kept: %v", status)
+	}
+	if snapshot := s.Snapshot(); !snapshot.Jailed || snapshot.MMI != 4 {
+		t.Errorf("invalid snapshot: %v", snapshot)
+	}
+
+	// a later break resets it as usual
+	if _, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start.Add(time.Hour), testSamples()); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.Status(); !status.Bad.Equal(time.Unix(0, 0)) {
+		t.Errorf("noise state not reset: %v", status)
+	}
+}
+
+func TestStreamEvent(t *testing.T) {
+	s := &Stream{Name: "test", Rate: 50.0, Gain: 100000.0, Detector: &Detector{Short: 1.0, Long: 10.0, On: 4.0, Off: 1.5}}
+	if _, err := s.Init("NZ_TEST_10_HHZ", 0, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	packet := func(n int, amplitude float64) {
+		samples := make([]int32, 50)
+		for i := range samples {
+			samples[i] = (int32)(amplitude * math.Sin((float64)(i)))
+		}
+		if _, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start.Add(time.Duration(n)*time.Second), samples); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// quiet background
+	for n := 0; n < 20; n++ {
+		packet(n, 10.0)
+	}
+	if s.Status().Event {
+		t.Fatal("unexpected event in the background")
+	}
+
+	// a sudden strong signal isn't treated as noise
+	packet(20, 1000.0)
+	if !s.Status().Event {
+		t.Fatal("expected an event")
+	}
+	s.Flush(0, 8)
+	if s.Status().Jailed {
+		t.Error("stream jailed during an event")
+	}
+
+	// but the same level persisting is
+	for n := 21; n < 60; n++ {
+		packet(n, 1000.0)
+	}
+	if s.Status().Event {
+		t.Fatal("unexpected event for persistent noise")
+	}
+	s.Flush(0, 9)
+	if !s.Status().Jailed {
+		t.Error("persistently noisy stream not jailed")
+	}
+}
+
+func TestStreamJailMessage(t *testing.T) {
+	s := testStream(t)
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	s.good, s.bad, s.last = start, start, start
+
+	if _, ok := s.JailMessage(Message{}); ok {
+		t.Fatal("unexpected jail message")
+	}
+
+	// noisy for longer than the probation period
+	s.last = start.Add(15 * time.Minute)
+	if s.Flush(0, 5) {
+		t.Fatal("unexpected flush when jailed")
+	}
+	m, ok := s.JailMessage(Message{Source: "NZ.TEST", Quality: "measured", Ratio: 2.5, Bound: "lower"})
+	if !ok {
+		t.Fatal("expected a jail message")
+	}
+	if m.Quality != "suspect" || m.Reason != "noisy" || m.MMI != 5 || m.Duration != 900.0 || m.Source != "NZ.TEST" || m.Ratio != 0.0 || m.Bound != "" {
+		t.Errorf("invalid jail message: %v", m)
+	}
+	if _, ok := s.JailMessage(Message{}); ok {
+		t.Error("unexpected repeated jail message")
+	}
+
+	// quiet for longer than the probation period
+	s.last = start.Add(30 * time.Minute)
+	if !s.Flush(0, 1) {
+		t.Fatal("expected a flush once released")
+	}
+	m, ok = s.JailMessage(Message{Source: "NZ.TEST"})
+	if !ok {
+		t.Fatal("expected a release message")
+	}
+	if m.Quality != "measured" || m.Reason != "released" || m.MMI != 1 || m.Duration != 900.0 {
+		t.Errorf("invalid release message: %v", m)
+	}
+}
+
+func TestStreamOverride(t *testing.T) {
+	s := testStream(t)
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	s.good, s.bad, s.last = start, start, start
+
+	// a quiet stream can be manually jailed
+	s.SetOverride(&Override{Jailed: true, Until: time.Now().Add(time.Hour)})
+	if s.Flush(0, 1) {
+		t.Error("unexpected flush when manually jailed")
+	}
+	m, ok := s.JailMessage(Message{})
+	if !ok || m.Quality != "suspect" || m.Reason != "manual" {
+		t.Errorf("invalid manual jail message: %v %v", ok, m)
+	}
+	if o := s.Snapshot().Override; o == nil || !o.Jailed {
+		t.Errorf("override not kept in the snapshot: %v", o)
+	}
+
+	// and a noisy stream can be manually released
+	s.last = start.Add(15 * time.Minute)
+	s.SetOverride(&Override{Jailed: false, Until: time.Now().Add(time.Hour)})
+	if !s.Flush(0, 5) {
+		t.Error("expected a flush when manually released")
+	}
+	if s.Status().Jailed {
+		t.Error("manually released stream jailed")
+	}
+
+	// an expired override returns to the noise checks
+	s.SetOverride(&Override{Jailed: false, Until: time.Now().Add(-time.Second)})
+	s.last = start.Add(30 * time.Minute)
+	if s.Flush(0, 6) {
+		t.Error("unexpected flush after the override expired")
+	}
+	if s.Status().Override != nil {
+		t.Error("expired override not cleared")
+	}
+}
+
+func TestStreamBaseline(t *testing.T) {
+	s := &Stream{Name: "test", Rate: 50.0, Gain: 100000.0, Baseline: 1.0}
+	if _, err := s.Init("NZ_TEST_10_HHZ", 10*time.Minute, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2015, 8, 17, 8, 30, 0, 0, time.UTC)
+	packet := func(n int, amplitude float64) Message {
+		samples := make([]int32, 50)
+		for i := range samples {
+			samples[i] = (int32)(amplitude * math.Sin((float64)(i)))
+		}
+		m, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start.Add(time.Duration(n)*time.Second), samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	// a busy hour, with a background intensity above the noise level
+	for n := 0; n < 5400; n++ {
+		packet(n, 5000.0)
+	}
+	if level := s.NoiseLevel(); level <= 2 {
+		t.Errorf("noise level not raised by the background: %d", level)
+	}
+
+	// a signal well above the background
+	if m := packet(5000, 50000.0); m.Ratio < 5.0 {
+		t.Errorf("invalid background ratio: %g", m.Ratio)
+	}
+
+	if snapshot := s.Snapshot(); snapshot.Background == nil || snapshot.Background == s.b {
+		t.Error("expected a copy of the background in the snapshot")
+	}
+}
+
+func TestStreamClipped(t *testing.T) {
+	s := testStream(t)
+	s.FullScale = 8388608
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+
+	m, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start, testSamples())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Quality != "measured" || m.Bound != "" {
+		t.Errorf("unexpected clipping: %s %s", m.Quality, m.Bound)
+	}
+
+	samples := testSamples()
+	samples[10], samples[11] = 8388607, 8388607
+	m, err = s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start.Add(time.Duration(len(samples))*time.Second/50), samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Quality != "clipped" || m.Bound != "lower" {
+		t.Errorf("expected clipping: %s %s", m.Quality, m.Bound)
+	}
+	if s.Status().Clips != 1 {
+		t.Errorf("invalid clips: %d (found) != %d (expected)", s.Status().Clips, 1)
+	}
+}
+
+func TestStreamChecks(t *testing.T) {
+	s := testStream(t)
+	s.Checks = &Checks{Flat: 1.0, Suppress: true}
+
+	start := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	m, err := s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start, make([]int32, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Quality != "suspect" || m.Reason != "flat" || s.Status().Problem != "flat" {
+		t.Errorf("expected a flat stream: %s %s", m.Quality, m.Reason)
+	}
+	if s.Flush(time.Second, m.MMI) {
+		t.Error("unexpected flush of suppressed data")
+	}
+
+	// and recovers with good data
+	m, err = s.ProcessSamples("NZ.TEST", "NZ_TEST_10_HHZ", start.Add(2*time.Second), testSamples())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Quality != "measured" || s.Status().Problem != "" {
+		t.Errorf("unexpected problem: %s %s", m.Quality, m.Reason)
+	}
+}
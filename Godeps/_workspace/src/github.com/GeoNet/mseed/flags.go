@@ -0,0 +1,34 @@
+package mseed
+
+// fixed section data header activity flags
+const (
+	ActCalibration   byte = 1 << 0 // calibration signals present
+	ActTimeCorrected byte = 1 << 1 // time correction applied
+	ActEventStart    byte = 1 << 2 // beginning of an event, station trigger
+	ActEventEnd      byte = 1 << 3 // end of the event, station detriggers
+	ActLeapPositive  byte = 1 << 4 // a positive leap second happened during this record
+	ActLeapNegative  byte = 1 << 5 // a negative leap second happened during this record
+	ActEventProgress byte = 1 << 6 // event in progress
+)
+
+// fixed section data header i/o and clock flags
+const (
+	IOParityError byte = 1 << 0 // station volume parity error possibly present
+	IOLongRecord  byte = 1 << 1 // long record read (possibly no problem)
+	IOShortRecord byte = 1 << 2 // short record read (record padded)
+	IOSeriesStart byte = 1 << 3 // start of time series
+	IOSeriesEnd   byte = 1 << 4 // end of time series
+	IOClockLocked byte = 1 << 5 // clock locked
+)
+
+// fixed section data header data quality flags
+const (
+	DQSaturation     byte = 1 << 0 // amplifier saturation detected
+	DQClipping       byte = 1 << 1 // digitizer clipping detected
+	DQSpikes         byte = 1 << 2 // spikes detected
+	DQGlitches       byte = 1 << 3 // glitches detected
+	DQMissing        byte = 1 << 4 // missing/padded data present
+	DQSyncError      byte = 1 << 5 // telemetry synchronization error
+	DQFilterCharging byte = 1 << 6 // a digital filter may be charging
+	DQTimeQuestion   byte = 1 << 7 // time tag is questionable
+)
@@ -38,6 +38,41 @@ func (m *MSRecord) Channel() string {
 func (m *MSRecord) Dataquality() byte {
 	return byte(m.dataquality)
 }
+func (m *MSRecord) ActFlags() byte {
+	if m.fsdh == nil {
+		return 0
+	}
+	return byte(m.fsdh.act_flags)
+}
+func (m *MSRecord) IOFlags() byte {
+	if m.fsdh == nil {
+		return 0
+	}
+	return byte(m.fsdh.io_flags)
+}
+func (m *MSRecord) DQFlags() byte {
+	if m.fsdh == nil {
+		return 0
+	}
+	return byte(m.fsdh.dq_flags)
+}
+
+// blockette 1001 timing quality as a percentage, or -1 if not given
+func (m *MSRecord) TimingQuality() int {
+	if m.Blkt1001 == nil {
+		return -1
+	}
+	return int(m.Blkt1001.timing_qual)
+}
+
+// blockette 1001 microsecond offset, this is already included in the start time
+func (m *MSRecord) Microseconds() int {
+	if m.Blkt1001 == nil {
+		return 0
+	}
+	return int(m.Blkt1001.usec)
+}
+
 func (m *MSRecord) Starttime() time.Time {
 	sec := int64(m.starttime) / 1000000
 	nsec := 1000 * (int64(m.starttime) % 1000000)
@@ -152,6 +152,10 @@ func (s *SLCD) Disconnect() int {
 	return 0
 }
 
+func (s *SLCD) Connected() bool {
+	return (*_Ctype_SLCD)(s).link != -1
+}
+
 func (s *SLCD) Ping(serverid, site string) int {
 	cserverid := C.CString(serverid)
 	defer C.free(unsafe.Pointer(cserverid))
@@ -337,15 +337,7 @@ func GetAuth(accessKey string, secretKey, token string, expiration time.Time) (a
 	cred, err := GetInstanceCredentials()
 	if err == nil {
 		// Found auth, return
-		auth.AccessKey = cred.AccessKeyId
-		auth.SecretKey = cred.SecretAccessKey
-		auth.token = cred.Token
-		exptdate, err := time.Parse("2006-01-02T15:04:05Z", cred.Expiration)
-		if err != nil {
-			err = fmt.Errorf("Error Parsing expiration date: cred.Expiration :%s , error: %s \n", cred.Expiration, err)
-		}
-		auth.expiration = exptdate
-		return auth, err
+		return credentialsAuth(cred)
 	}
 
 	// Next try getting auth from the credentials file
@@ -359,9 +351,30 @@ func GetAuth(accessKey string, secretKey, token string, expiration time.Time) (a
 	return auth, err
 }
 
+// instanceAuth creates an Auth from the instance role credentials.
+func instanceAuth() (Auth, error) {
+	cred, err := GetInstanceCredentials()
+	if err != nil {
+		return Auth{}, err
+	}
+	return credentialsAuth(cred)
+}
+
+func credentialsAuth(cred credentials) (auth Auth, err error) {
+	auth.AccessKey = cred.AccessKeyId
+	auth.SecretKey = cred.SecretAccessKey
+	auth.token = cred.Token
+	exptdate, err := time.Parse("2006-01-02T15:04:05Z", cred.Expiration)
+	if err != nil {
+		err = fmt.Errorf("Error Parsing expiration date: cred.Expiration :%s , error: %s \n", cred.Expiration, err)
+	}
+	auth.expiration = exptdate
+	return auth, err
+}
+
 // EnvAuth creates an Auth based on environment information.
 // The AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment
-// variables are used.
+// variables are used, along with AWS_SESSION_TOKEN if present.
 func EnvAuth() (auth Auth, err error) {
 	auth.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
 	if auth.AccessKey == "" {
@@ -372,6 +385,8 @@ func EnvAuth() (auth Auth, err error) {
 	if auth.SecretKey == "" {
 		auth.SecretKey = os.Getenv("AWS_SECRET_KEY")
 	}
+
+	auth.token = os.Getenv("AWS_SESSION_TOKEN")
 	if auth.AccessKey == "" {
 		err = errors.New("AWS_ACCESS_KEY_ID or AWS_ACCESS_KEY not found in environment")
 	}
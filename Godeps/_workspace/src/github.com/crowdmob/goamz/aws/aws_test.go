@@ -1,6 +1,7 @@
 package aws_test
 
 import (
+	"errors"
 	"github.com/crowdmob/goamz/aws"
 	"gopkg.in/check.v1"
 	"io/ioutil"
@@ -63,6 +64,17 @@ func (s *S) TestEnvAuthAlt(c *check.C) {
 	c.Assert(auth, check.Equals, aws.Auth{SecretKey: "secret", AccessKey: "access"})
 }
 
+func (s *S) TestEnvAuthSessionToken(c *check.C) {
+	os.Clearenv()
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("AWS_ACCESS_KEY_ID", "access")
+	os.Setenv("AWS_SESSION_TOKEN", "token")
+	auth, err := aws.EnvAuth()
+	c.Assert(err, check.IsNil)
+	c.Assert(auth.AccessKey, check.Equals, "access")
+	c.Assert(auth.Token(), check.Equals, "token")
+}
+
 func (s *S) TestGetAuthStatic(c *check.C) {
 	exptdate := time.Now().Add(time.Hour)
 	auth, err := aws.GetAuth("access", "secret", "token", exptdate)
@@ -138,3 +150,94 @@ aws_secret_access_key=key1
 	c.Assert(profile2.SecretKey, check.Equals, "key2")
 	c.Assert(profile2.Token(), check.Equals, "token1")
 }
+
+func (s *S) TestCredentialsStatic(c *check.C) {
+	creds, err := aws.NewCredentials("access", "secret", time.Minute)
+	c.Assert(err, check.IsNil)
+	auth, err := creds.Auth()
+	c.Assert(err, check.IsNil)
+	c.Assert(auth, check.Equals, aws.Auth{SecretKey: "secret", AccessKey: "access"})
+}
+
+func (s *S) TestCredentialsRefresh(c *check.C) {
+	var count int
+	creds, err := aws.NewRefreshingCredentials(func() (aws.Auth, error) {
+		count++
+		return *aws.NewAuth("access", "secret", "token", time.Now().Add(time.Duration(count)*time.Hour)), nil
+	}, 90*time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+
+	// within the refresh window
+	auth, err := creds.Auth()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 2)
+	c.Assert(auth.Token(), check.Equals, "token")
+
+	// outside the refresh window
+	_, err = creds.Auth()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 2)
+}
+
+func (s *S) TestCredentialsRefreshFailure(c *check.C) {
+	var fail bool
+	creds, err := aws.NewRefreshingCredentials(func() (aws.Auth, error) {
+		if fail {
+			return aws.Auth{}, errors.New("refresh failed")
+		}
+		return *aws.NewAuth("access", "secret", "token", time.Now().Add(-time.Minute)), nil
+	}, time.Minute)
+	c.Assert(err, check.IsNil)
+
+	fail = true
+	auth, err := creds.Auth()
+	c.Assert(err, check.ErrorMatches, "refresh failed")
+	c.Assert(auth.AccessKey, check.Equals, "access")
+}
+
+func (s *S) TestCredentialsFileReread(c *check.C) {
+	file, err := ioutil.TempFile("", "creds")
+	c.Assert(err, check.IsNil)
+	defer os.Remove(file.Name())
+
+	write := func(key string) {
+		err := ioutil.WriteFile(file.Name(), []byte("[default]\naws_access_key_id = "+key+"\naws_secret_access_key = secret\n"), 0600)
+		c.Assert(err, check.IsNil)
+	}
+	write("keyid1")
+
+	// a refresh window longer than the re-read interval
+	creds, err := aws.NewFileCredentials(file.Name(), "", time.Hour, 30*time.Minute)
+	c.Assert(err, check.IsNil)
+
+	write("keyid2")
+	for i := 0; i < 3; i++ {
+		auth, err := creds.Auth()
+		c.Assert(err, check.IsNil)
+		c.Assert(auth.AccessKey, check.Equals, "keyid1")
+	}
+
+	// a short interval picks up the change
+	creds, err = aws.NewFileCredentials(file.Name(), "", time.Hour, time.Nanosecond)
+	c.Assert(err, check.IsNil)
+	write("keyid3")
+	time.Sleep(time.Millisecond)
+	auth, err := creds.Auth()
+	c.Assert(err, check.IsNil)
+	c.Assert(auth.AccessKey, check.Equals, "keyid3")
+}
+
+func (s *S) TestCredentialsEnvReread(c *check.C) {
+	os.Clearenv()
+	os.Setenv("AWS_ACCESS_KEY_ID", "access1")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	creds, err := aws.NewCredentials("", "", time.Hour)
+	c.Assert(err, check.IsNil)
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "access2")
+	auth, err := creds.Auth()
+	c.Assert(err, check.IsNil)
+	c.Assert(auth.AccessKey, check.Equals, "access1")
+}
@@ -0,0 +1,105 @@
+package aws
+
+import (
+	"sync"
+	"time"
+)
+
+// CredentialsInterval is how often credentials from the environment or a credentials file
+// are read again, as they have no expiry time of their own.
+var CredentialsInterval = 15 * time.Minute
+
+// Credentials holds an Auth which is refreshed before it expires, allowing long running
+// clients to use instance role or session credentials without restarting.
+type Credentials struct {
+	mu       sync.Mutex
+	auth     Auth
+	window   time.Duration
+	interval time.Duration
+	read     time.Time
+	refresh  func() (Auth, error)
+}
+
+// NewCredentials creates Credentials based on either the passed in keys, which never
+// expire, or by falling through to the environment, instance role and credentials file
+// as per GetAuth. Only the source which succeeded is used for later refreshes, instance
+// role credentials are refreshed once they are within the window of expiring, while
+// environment and credentials file settings are read again every CredentialsInterval.
+func NewCredentials(accessKey, secretKey string, window time.Duration) (*Credentials, error) {
+	if accessKey != "" && secretKey != "" {
+		return NewRefreshingCredentials(func() (Auth, error) {
+			return Auth{AccessKey: accessKey, SecretKey: secretKey}, nil
+		}, window)
+	}
+
+	if auth, err := EnvAuth(); err == nil {
+		return newCredentials(auth, EnvAuth, window, CredentialsInterval), nil
+	}
+
+	if auth, err := instanceAuth(); err == nil {
+		return newCredentials(auth, instanceAuth, window, 0), nil
+	}
+
+	return NewFileCredentials("", "", window, CredentialsInterval)
+}
+
+// NewFileCredentials creates Credentials from a profile in a credentials file, as per
+// CredentialFileAuth, which is read again every interval.
+func NewFileCredentials(filePath, profile string, window, interval time.Duration) (*Credentials, error) {
+	refresh := func() (Auth, error) {
+		auth, err := CredentialFileAuth(filePath, profile, 0)
+		auth.expiration = time.Time{}
+		return auth, err
+	}
+	auth, err := refresh()
+	if err != nil {
+		return nil, err
+	}
+	return newCredentials(auth, refresh, window, interval), nil
+}
+
+// NewRefreshingCredentials creates Credentials which call refresh to get an initial Auth,
+// and again whenever the current Auth is within the window of expiring. An Auth without an
+// expiration time is never refreshed.
+func NewRefreshingCredentials(refresh func() (Auth, error), window time.Duration) (*Credentials, error) {
+	auth, err := refresh()
+	if err != nil {
+		return nil, err
+	}
+	return newCredentials(auth, refresh, window, 0), nil
+}
+
+func newCredentials(auth Auth, refresh func() (Auth, error), window, interval time.Duration) *Credentials {
+	return &Credentials{auth: auth, window: window, interval: interval, read: time.Now(), refresh: refresh}
+}
+
+// whether the current Auth is about to expire or, without an expiry, is due to be read again
+func (c *Credentials) due() bool {
+	if !c.auth.expiration.IsZero() {
+		return !time.Now().Add(c.window).Before(c.auth.expiration)
+	}
+	return c.interval > 0 && !time.Now().Before(c.read.Add(c.interval))
+}
+
+// Auth returns the current credentials, refreshing them first if they are about to expire.
+// If a refresh fails the previous credentials are returned, along with the error if they
+// have already expired.
+func (c *Credentials) Auth() (Auth, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.due() {
+		return c.auth, nil
+	}
+
+	auth, err := c.refresh()
+	if err != nil {
+		if c.auth.expiration.IsZero() || time.Now().Before(c.auth.expiration) {
+			return c.auth, nil
+		}
+		return c.auth, err
+	}
+	c.auth, c.read = auth, time.Now()
+
+	return c.auth, nil
+}
@@ -0,0 +1,181 @@
+// gosns - Go packages to interact with the Amazon SNS Web Services.
+//
+// depends on https://wiki.ubuntu.com/goamz
+//
+// This is a local addition to the vendored goamz tree, with a different API to the
+// upstream crowdmob/goamz/sns package, so it must not be replaced by a godep restore.
+package sns
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/crowdmob/goamz/aws"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The SNS type encapsulates operation with an SNS region.
+type SNS struct {
+	aws.Auth
+	aws.Region
+	private byte // Reserve the right of using private data.
+
+	credentials *aws.Credentials // refreshing credentials, if any
+}
+
+// New creates a new SNS client from an existing aws.Auth
+func New(auth aws.Auth, region aws.Region) *SNS {
+	return &SNS{Auth: auth, Region: region}
+}
+
+// NewWithCredentials creates a new SNS client which refreshes its credentials before they expire
+func NewWithCredentials(credentials *aws.Credentials, region aws.Region) (*SNS, error) {
+	auth, err := credentials.Auth()
+	if err != nil {
+		return nil, err
+	}
+	return &SNS{Auth: auth, Region: region, credentials: credentials}, nil
+}
+
+type PublishResponse struct {
+	MessageId        string `xml:"PublishResult>MessageId"`
+	ResponseMetadata ResponseMetadata
+}
+
+type ResponseMetadata struct {
+	RequestId string
+}
+
+type Error struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	RequestId  string
+}
+
+func (err *Error) Error() string {
+	if err.Code == "" {
+		return err.Message
+	}
+	return fmt.Sprintf("%s (%s)", err.Message, err.Code)
+}
+
+type xmlErrors struct {
+	RequestId string
+	Error     Error
+}
+
+// Publish sends a message to the given topic
+func (s *SNS) Publish(TopicArn string, Message string) (resp *PublishResponse, err error) {
+	return s.PublishWithAttributes(TopicArn, Message, map[string]string{})
+}
+
+// A typed message attribute, subscription filter policies can only match numerically on Number attributes
+type MessageAttribute struct {
+	DataType string // String or Number
+	Value    string
+}
+
+// PublishWithAttributes sends a message with string message attributes to the given topic
+func (s *SNS) PublishWithAttributes(TopicArn string, Message string, MessageAttributes map[string]string) (resp *PublishResponse, err error) {
+	attributes := make(map[string]MessageAttribute)
+	for k, v := range MessageAttributes {
+		attributes[k] = MessageAttribute{DataType: "String", Value: v}
+	}
+	return s.PublishWithTypedAttributes(TopicArn, Message, attributes)
+}
+
+// PublishWithTypedAttributes sends a message with string or number message attributes to the given topic
+func (s *SNS) PublishWithTypedAttributes(TopicArn string, Message string, MessageAttributes map[string]MessageAttribute) (resp *PublishResponse, err error) {
+	resp = &PublishResponse{}
+	params := makeParams("Publish")
+
+	params["TopicArn"] = TopicArn
+	params["Message"] = Message
+
+	i := 1
+	for k, v := range MessageAttributes {
+		params[fmt.Sprintf("MessageAttributes.entry.%d.Name", i)] = k
+		params[fmt.Sprintf("MessageAttributes.entry.%d.Value.StringValue", i)] = v.Value
+		params[fmt.Sprintf("MessageAttributes.entry.%d.Value.DataType", i)] = v.DataType
+		i++
+	}
+
+	err = s.query(params, resp)
+	return
+}
+
+func (s *SNS) query(params map[string]string, resp interface{}) error {
+	url_, err := url.Parse(s.Region.SNSEndpoint)
+	if err != nil {
+		return err
+	}
+
+	params["Version"] = "2010-03-31"
+	hreq, err := http.NewRequest("POST", url_.String(), strings.NewReader(multimap(params).Encode()))
+	if err != nil {
+		return err
+	}
+
+	hreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	hreq.Header.Set("X-Amz-Date", time.Now().UTC().Format(aws.ISO8601BasicFormat))
+
+	auth := s.Auth
+	if s.credentials != nil {
+		if auth, err = s.credentials.Auth(); err != nil {
+			return err
+		}
+	}
+
+	if auth.Token() != "" {
+		hreq.Header.Set("X-Amz-Security-Token", auth.Token())
+	}
+
+	signer := aws.NewV4Signer(auth, "sns", s.Region)
+	signer.Sign(hreq)
+
+	r, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != 200 {
+		return buildError(r)
+	}
+	err = xml.NewDecoder(r.Body).Decode(resp)
+	io.Copy(ioutil.Discard, r.Body)
+
+	return err
+}
+
+func buildError(r *http.Response) error {
+	errors := xmlErrors{}
+	xml.NewDecoder(r.Body).Decode(&errors)
+	err := errors.Error
+	err.RequestId = errors.RequestId
+	err.StatusCode = r.StatusCode
+	if err.Message == "" {
+		err.Message = r.Status
+	}
+	return &err
+}
+
+func makeParams(action string) map[string]string {
+	params := make(map[string]string)
+	params["Action"] = action
+	return params
+}
+
+func multimap(p map[string]string) url.Values {
+	q := make(url.Values, len(p))
+	for k, v := range p {
+		q[k] = []string{v}
+	}
+	return q
+}
@@ -0,0 +1,77 @@
+package sns
+
+import (
+	"github.com/crowdmob/goamz/aws"
+	"gopkg.in/check.v1"
+	"strings"
+)
+
+var _ = check.Suite(&S{})
+
+type S struct {
+	HTTPSuite
+	sns *SNS
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	s.HTTPSuite.SetUpSuite(c)
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	s.sns = New(auth, aws.Region{Name: "us-east-1", SNSEndpoint: testServer.URL})
+}
+
+func (s *S) TestPublish(c *check.C) {
+	testServer.PrepareResponse(200, nil, TestPublishXmlOK)
+
+	resp, err := s.sns.Publish("arn:aws:sns:us-east-1:123456789012:testTopic", "This is a test message")
+	req := testServer.WaitRequest()
+
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.URL.Path, check.Equals, "/")
+	c.Assert(req.Form["Action"], check.DeepEquals, []string{"Publish"})
+	c.Assert(req.Form["TopicArn"], check.DeepEquals, []string{"arn:aws:sns:us-east-1:123456789012:testTopic"})
+	c.Assert(req.Form["Message"], check.DeepEquals, []string{"This is a test message"})
+	c.Assert(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=abc/"), check.Equals, true)
+
+	c.Assert(resp.MessageId, check.Equals, "94f20ce6-13c5-43a0-9a9e-ca52d816e90b")
+	c.Assert(resp.ResponseMetadata.RequestId, check.Equals, "f187a3c1-376f-11df-8963-01868b7c937a")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestPublishWithAttributes(c *check.C) {
+	testServer.PrepareResponse(200, nil, TestPublishXmlOK)
+
+	_, err := s.sns.PublishWithAttributes("arn:aws:sns:us-east-1:123456789012:testTopic", "This is a test message", map[string]string{"red": "fish"})
+	req := testServer.WaitRequest()
+
+	c.Assert(req.Form["MessageAttributes.entry.1.Name"], check.DeepEquals, []string{"red"})
+	c.Assert(req.Form["MessageAttributes.entry.1.Value.StringValue"], check.DeepEquals, []string{"fish"})
+	c.Assert(req.Form["MessageAttributes.entry.1.Value.DataType"], check.DeepEquals, []string{"String"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestPublishWithTypedAttributes(c *check.C) {
+	testServer.PrepareResponse(200, nil, TestPublishXmlOK)
+
+	_, err := s.sns.PublishWithTypedAttributes("arn:aws:sns:us-east-1:123456789012:testTopic", "This is a test message", map[string]MessageAttribute{"MMI": {DataType: "Number", Value: "5"}})
+	req := testServer.WaitRequest()
+
+	c.Assert(req.Form["MessageAttributes.entry.1.Name"], check.DeepEquals, []string{"MMI"})
+	c.Assert(req.Form["MessageAttributes.entry.1.Value.StringValue"], check.DeepEquals, []string{"5"})
+	c.Assert(req.Form["MessageAttributes.entry.1.Value.DataType"], check.DeepEquals, []string{"Number"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestPublishNotFound(c *check.C) {
+	testServer.PrepareResponse(404, nil, TestPublishXmlNotFound)
+
+	_, err := s.sns.Publish("arn:aws:sns:us-east-1:123456789012:missing", "This is a test message")
+	testServer.WaitRequest()
+
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*Error)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.StatusCode, check.Equals, 404)
+	c.Assert(e.Code, check.Equals, "NotFound")
+	c.Assert(e.RequestId, check.Equals, "9dd01905-5012-5f99-8663-4b3ecd0dfaef")
+	c.Assert(err.Error(), check.Equals, "Topic does not exist (NotFound)")
+}
@@ -32,6 +32,9 @@ type SQS struct {
 	aws.Auth
 	aws.Region
 	private byte // Reserve the right of using private data.
+
+	credentials *aws.Credentials // refreshing credentials, if any
+	endpoint    *url.URL         // custom endpoint, if any
 }
 
 // NewFrom Create A new SQS Client given an access and secret Key
@@ -50,7 +53,31 @@ func NewFrom(accessKey, secretKey, region string) (*SQS, error) {
 
 // NewFrom Create A new SQS Client from an exisisting aws.Auth
 func New(auth aws.Auth, region aws.Region) *SQS {
-	return &SQS{auth, region, 0}
+	return &SQS{Auth: auth, Region: region}
+}
+
+// NewWithCredentials creates a new SQS client which refreshes its credentials before they expire
+func NewWithCredentials(credentials *aws.Credentials, region aws.Region) (*SQS, error) {
+	auth, err := credentials.Auth()
+	if err != nil {
+		return nil, err
+	}
+	return &SQS{Auth: auth, Region: region, credentials: credentials}, nil
+}
+
+// SetEndpoint sends all requests, including those made to queue urls, to a custom endpoint
+// such as a VPC endpoint or a local stand-in. Requests are still signed for the client region.
+func (s *SQS) SetEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid endpoint url: %s", endpoint)
+	}
+	s.Region.SQSEndpoint = endpoint
+	s.endpoint = u
+	return nil
 }
 
 // Queue Reference to a Queue
@@ -471,6 +498,11 @@ func (s *SQS) query(queueUrl string, params map[string]string, resp interface{})
 		return err
 	}
 
+	// queue urls refer to the public endpoint
+	if s.endpoint != nil {
+		url_.Scheme, url_.Host = s.endpoint.Scheme, s.endpoint.Host
+	}
+
 	params["Version"] = "2012-11-05"
 	hreq, err := http.NewRequest("POST", url_.String(), strings.NewReader(multimap(params).Encode()))
 	if err != nil {
@@ -480,11 +512,18 @@ func (s *SQS) query(queueUrl string, params map[string]string, resp interface{})
 	hreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	hreq.Header.Set("X-Amz-Date", time.Now().UTC().Format(aws.ISO8601BasicFormat))
 
-	if s.Auth.Token() != "" {
-		hreq.Header.Set("X-Amz-Security-Token", s.Auth.Token())
+	auth := s.Auth
+	if s.credentials != nil {
+		if auth, err = s.credentials.Auth(); err != nil {
+			return err
+		}
+	}
+
+	if auth.Token() != "" {
+		hreq.Header.Set("X-Amz-Security-Token", auth.Token())
 	}
 
-	signer := aws.NewV4Signer(s.Auth, "sqs", s.Region)
+	signer := aws.NewV4Signer(auth, "sqs", s.Region)
 	signer.Sign(hreq)
 
 	r, err := http.DefaultClient.Do(hreq)
@@ -497,7 +536,7 @@ func (s *SQS) query(queueUrl string, params map[string]string, resp interface{})
 
 	if debug {
 		dump, _ := httputil.DumpResponse(r, true)
-		log.Printf("DUMP:\n", string(dump))
+		log.Printf("DUMP:\n%s", string(dump))
 	}
 
 	if r.StatusCode != 200 {
@@ -7,6 +7,8 @@ import (
 	"gopkg.in/check.v1"
 	"hash"
 	"reflect"
+	"strings"
+	"time"
 )
 
 var _ = check.Suite(&S{})
@@ -349,3 +351,39 @@ func (s *S) TestGetQueueAttributes(c *check.C) {
 
 	c.Assert(err, check.IsNil)
 }
+
+func (s *S) TestSignatureV4(c *check.C) {
+	testServer.PrepareResponse(200, nil, TestSendMessageXmlOK)
+
+	auth := aws.NewAuth("abc", "123", "token", time.Now().Add(time.Hour))
+	q := &Queue{New(*auth, aws.Region{Name: "us-east-1", SQSEndpoint: testServer.URL}), testServer.URL + "/123456789012/testQueue/"}
+	_, err := q.SendMessage("This is a test message")
+	req := testServer.WaitRequest()
+
+	c.Assert(err, check.IsNil)
+	c.Assert(req.Header.Get("X-Amz-Security-Token"), check.Equals, "token")
+	c.Assert(req.Form["Signature"], check.IsNil)
+	c.Assert(req.Form["SignatureVersion"], check.IsNil)
+
+	authorization := req.Header.Get("Authorization")
+	c.Assert(strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=abc/"), check.Equals, true)
+	c.Assert(strings.Contains(authorization, "/us-east-1/sqs/aws4_request"), check.Equals, true)
+	c.Assert(strings.Contains(authorization, "x-amz-security-token"), check.Equals, true)
+}
+
+func (s *S) TestSetEndpoint(c *check.C) {
+	testServer.PrepareResponse(200, nil, TestSendMessageXmlOK)
+
+	sqs := New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.USEast)
+	c.Assert(sqs.SetEndpoint("localhost:4455"), check.NotNil)
+	c.Assert(sqs.SetEndpoint(testServer.URL), check.IsNil)
+
+	q := sqs.QueueFromArn("https://sqs.us-east-1.amazonaws.com/123456789012/testQueue/")
+	_, err := q.SendMessage("This is a test message")
+	req := testServer.WaitRequest()
+
+	c.Assert(err, check.IsNil)
+	c.Assert(req.URL.Path, check.Equals, "/123456789012/testQueue/")
+	c.Assert(req.Host, check.Equals, "localhost:4455")
+	c.Assert(strings.Contains(req.Header.Get("Authorization"), "/us-east-1/sqs/aws4_request"), check.Equals, true)
+}
@@ -0,0 +1,177 @@
+// Package sqstest provides an in-process SQS stand-in for testing clients against a
+// custom endpoint, in the manner of net/http/httptest.
+package sqstest
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"github.com/crowdmob/goamz/sqs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fake is an in-process SQS stand-in which keeps messages in memory, it supports just
+// enough of the query API to exercise a client end to end against a custom endpoint.
+type Fake struct {
+	Region string
+
+	mu     sync.Mutex
+	url    string
+	queues map[string][]sqs.Message
+	count  int
+}
+
+func NewFake(region string) *Fake {
+	return &Fake{Region: region, queues: make(map[string][]sqs.Message)}
+}
+
+type fakeCreateQueueResponse struct {
+	XMLName  xml.Name `xml:"CreateQueueResponse"`
+	QueueUrl string   `xml:"CreateQueueResult>QueueUrl"`
+}
+
+type fakeGetQueueUrlResponse struct {
+	XMLName  xml.Name `xml:"GetQueueUrlResponse"`
+	QueueUrl string   `xml:"GetQueueUrlResult>QueueUrl"`
+}
+
+type fakeSendMessageResponse struct {
+	XMLName      xml.Name `xml:"SendMessageResponse"`
+	AttributeMD5 string   `xml:"SendMessageResult>MD5OfMessageAttributes,omitempty"`
+	MD5          string   `xml:"SendMessageResult>MD5OfMessageBody"`
+	Id           string   `xml:"SendMessageResult>MessageId"`
+}
+
+type fakeReceiveMessageResponse struct {
+	XMLName  xml.Name      `xml:"ReceiveMessageResponse"`
+	Messages []sqs.Message `xml:"ReceiveMessageResult>Message"`
+}
+
+type fakeErrorResponse struct {
+	XMLName xml.Name  `xml:"ErrorResponse"`
+	Error   sqs.Error `xml:"Error"`
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// only accept requests signed for the expected region
+	scope := fmt.Sprintf("/%s/sqs/aws4_request", f.Region)
+	if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") || !strings.Contains(auth, scope) {
+		f.fail(w, http.StatusForbidden, "IncompleteSignature", "request not signed for "+f.Region)
+		return
+	}
+
+	req.ParseForm()
+	queue := strings.Trim(req.URL.Path, "/")
+
+	switch action := req.Form.Get("Action"); action {
+	case "CreateQueue":
+		name := "123456789012/" + req.Form.Get("QueueName")
+		if _, ok := f.queues[name]; !ok {
+			f.queues[name] = nil
+		}
+		f.reply(w, fakeCreateQueueResponse{QueueUrl: f.queueUrl(name)})
+	case "GetQueueUrl":
+		name := "123456789012/" + req.Form.Get("QueueName")
+		if _, ok := f.queues[name]; !ok {
+			f.fail(w, http.StatusBadRequest, "AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist.")
+			return
+		}
+		f.reply(w, fakeGetQueueUrlResponse{QueueUrl: f.queueUrl(name)})
+	case "SendMessage":
+		if _, ok := f.queues[queue]; !ok {
+			f.fail(w, http.StatusBadRequest, "AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist.")
+			return
+		}
+		f.count++
+		msg := sqs.Message{
+			MessageId:     fmt.Sprintf("message-%d", f.count),
+			ReceiptHandle: fmt.Sprintf("receipt-%d", f.count),
+			Body:          req.Form.Get("MessageBody"),
+			MD5OfBody:     fmt.Sprintf("%x", md5.Sum([]byte(req.Form.Get("MessageBody")))),
+		}
+		attributes := make(map[string]string)
+		for i := 1; req.Form.Get(fmt.Sprintf("MessageAttribute.%d.Name", i)) != ""; i++ {
+			name := req.Form.Get(fmt.Sprintf("MessageAttribute.%d.Name", i))
+			value := req.Form.Get(fmt.Sprintf("MessageAttribute.%d.Value.StringValue", i))
+			attributes[name] = value
+			msg.MessageAttribute = append(msg.MessageAttribute, sqs.MessageAttribute{
+				Name:  name,
+				Value: sqs.MessageAttributeValue{DataType: "String", StringValue: value},
+			})
+		}
+		f.queues[queue] = append(f.queues[queue], msg)
+
+		resp := fakeSendMessageResponse{MD5: msg.MD5OfBody, Id: msg.MessageId}
+		if len(attributes) > 0 {
+			resp.AttributeMD5 = fmt.Sprintf("%x", attributeMD5(attributes))
+		}
+		f.reply(w, resp)
+	case "ReceiveMessage":
+		f.reply(w, fakeReceiveMessageResponse{Messages: f.queues[queue]})
+	case "DeleteMessage":
+		var keep []sqs.Message
+		for _, m := range f.queues[queue] {
+			if m.ReceiptHandle != req.Form.Get("ReceiptHandle") {
+				keep = append(keep, m)
+			}
+		}
+		f.queues[queue] = keep
+		f.reply(w, struct {
+			XMLName xml.Name `xml:"DeleteMessageResponse"`
+		}{})
+	default:
+		f.fail(w, http.StatusBadRequest, "InvalidAction", "unsupported action: "+action)
+	}
+}
+
+func (f *Fake) queueUrl(name string) string {
+	// mimic the public queue urls returned by SQS
+	return "https://sqs." + f.Region + ".amazonaws.com/" + name
+}
+
+func (f *Fake) reply(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	xml.NewEncoder(w).Encode(resp)
+}
+
+func (f *Fake) fail(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(fakeErrorResponse{Error: sqs.Error{Code: code, Message: message}})
+}
+
+// the md5 digest of string message attributes, as calculated by SQS
+func attributeMD5(attributes map[string]string) []byte {
+	var keys []string
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var encoded []byte
+	for _, k := range keys {
+		for _, v := range []string{k, "String"} {
+			encoded = append(encoded, length(v)...)
+			encoded = append(encoded, v...)
+		}
+		encoded = append(encoded, 0x01)
+		encoded = append(encoded, length(attributes[k])...)
+		encoded = append(encoded, attributes[k]...)
+	}
+
+	sum := md5.Sum(encoded)
+	return sum[:]
+}
+
+func length(s string) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(len(s)))
+	return b
+}
@@ -0,0 +1,73 @@
+package sqstest
+
+import (
+	"github.com/crowdmob/goamz/aws"
+	"github.com/crowdmob/goamz/sqs"
+	"gopkg.in/check.v1"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+var _ = check.Suite(&FakeSuite{})
+
+// FakeSuite runs the client against an in-process SQS stand-in over a custom endpoint.
+type FakeSuite struct {
+	fake   *Fake
+	server *httptest.Server
+	sqs    *sqs.SQS
+}
+
+func (s *FakeSuite) SetUpTest(c *check.C) {
+	s.fake = NewFake("ap-southeast-2")
+	s.server = httptest.NewServer(s.fake)
+
+	s.sqs = sqs.New(aws.Auth{AccessKey: "abc", SecretKey: "123"}, aws.APSoutheast2)
+	c.Assert(s.sqs.SetEndpoint(s.server.URL), check.IsNil)
+}
+
+func (s *FakeSuite) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+func (s *FakeSuite) TestSendAndReceive(c *check.C) {
+	_, err := s.sqs.CreateQueue("impact")
+	c.Assert(err, check.IsNil)
+
+	q, err := s.sqs.GetQueue("impact")
+	c.Assert(err, check.IsNil)
+	c.Assert(q.Url, check.Equals, "https://sqs.ap-southeast-2.amazonaws.com/123456789012/impact")
+
+	attributes := map[string]string{"source": "NZ.WEL", "MMI": "3"}
+	resp, err := q.SendMessageWithAttributes(`{"source":"NZ.WEL","MMI":3}`, attributes)
+	c.Assert(err, check.IsNil)
+	c.Assert(resp.Id, check.Equals, "message-1")
+
+	recv, err := q.ReceiveMessage(10)
+	c.Assert(err, check.IsNil)
+	c.Assert(recv.Messages, check.HasLen, 1)
+	c.Assert(recv.Messages[0].Body, check.Equals, `{"source":"NZ.WEL","MMI":3}`)
+	c.Assert(recv.Messages[0].MessageAttribute, check.HasLen, 2)
+
+	_, err = q.DeleteMessage(&recv.Messages[0])
+	c.Assert(err, check.IsNil)
+
+	recv, err = q.ReceiveMessage(10)
+	c.Assert(err, check.IsNil)
+	c.Assert(recv.Messages, check.HasLen, 0)
+}
+
+func (s *FakeSuite) TestMissingQueue(c *check.C) {
+	_, err := s.sqs.GetQueue("missing")
+	c.Assert(err, check.ErrorMatches, `The specified queue does not exist. \(AWS.SimpleQueueService.NonExistentQueue\)`)
+}
+
+func (s *FakeSuite) TestWrongRegion(c *check.C) {
+	s.sqs.Region.Name = "us-east-1"
+
+	_, err := s.sqs.CreateQueue("impact")
+	c.Assert(err, check.ErrorMatches, `request not signed for ap-southeast-2 \(IncompleteSignature\)`)
+}
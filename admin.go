@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"github.com/GeoNet/impact"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// manual stream jail and release requests
+type admin struct {
+	reg      *registry
+	snapshot string // where to save the noise state after a change, if given
+}
+
+// manually jail or release a stream, a nil override returns it to the noise checks
+func (r *registry) override(srcname string, o *impact.Override) error {
+	r.Lock()
+	defer r.Unlock()
+
+	s, ok := r.streams[srcname]
+	if !ok {
+		return fmt.Errorf("unknown stream: %s", srcname)
+	}
+	s.SetOverride(o)
+
+	return nil
+}
+
+// expects a POST with stream, action (jail, release or clear) and, other than for clear, a duration
+func (a *admin) handleOverride(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "expected a POST request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	srcname := r.FormValue("stream")
+	action := r.FormValue("action")
+
+	var o *impact.Override
+	switch action {
+	case "jail", "release":
+		d, err := time.ParseDuration(r.FormValue("duration"))
+		if err != nil || !(d > 0) {
+			http.Error(w, "expected a positive duration", http.StatusBadRequest)
+			return
+		}
+		o = &impact.Override{Jailed: action == "jail", Until: time.Now().Add(d)}
+	case "clear":
+	default:
+		http.Error(w, "expected an action of jail, release or clear", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.reg.override(srcname, o); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("[%s] manual %s: %s\n", srcname, action, r.FormValue("duration"))
+
+	// keep the override over a restart
+	if a.snapshot != "" {
+		if err := a.reg.save(a.snapshot); err != nil {
+			log.Printf("unable to save noise snapshot: %s [%s]\n", err, a.snapshot)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+// add the admin handlers to an http server
+func (a *admin) register(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/override", a.handleOverride)
+}
+
+// ask a running process to manually jail, release or clear a stream
+func requestOverride(address, action, srcname string, duration time.Duration) error {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	values := url.Values{"stream": {srcname}, "action": {action}}
+	if action != "clear" {
+		values.Set("duration", duration.String())
+	}
+
+	res, err := http.PostForm(strings.TrimRight(address, "/")+"/admin/override", values)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/GeoNet/impact"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// a structured configuration file, with global settings and stream defaults
+type configFile struct {
+	Global   map[string]interface{}  `yaml:"global"`   // command line flag settings
+	Defaults streamConfig            `yaml:"defaults"` // settings for all streams
+	Networks map[string]streamConfig `yaml:"networks"` // settings by network, e.g. NZ
+	Stations map[string]streamConfig `yaml:"stations"` // settings by station, e.g. NZ_WEL
+	Streams  map[string]streamConfig `yaml:"streams"`  // settings by stream, e.g. NZ_WEL_10_HHZ
+}
+
+// stream settings, any which are not given are inherited
+type streamConfig struct {
+	Name      *string  `yaml:"name"`
+	Latitude  *float32 `yaml:"latitude"`
+	Longitude *float32 `yaml:"longitude"`
+	Rate      *float64 `yaml:"rate"`
+	Gain      *float64 `yaml:"gain"`
+	Q         *float64 `yaml:"q"`
+
+	Level     *int32   `yaml:"level"`     // noise threshold level
+	Probation *seconds `yaml:"probation"` // noise probation period
+	Flush     *seconds `yaml:"flush"`     // heartbeat flush interval
+
+	Detector *impact.Detector `yaml:"detector"` // event detection settings
+	Baseline *float64         `yaml:"baseline"` // weeks of background history
+
+	FullScale *float64       `yaml:"fullscale"` // digitiser full scale in counts
+	Checks    *impact.Checks `yaml:"checks"`    // data quality checks
+	AdaptRate *bool          `yaml:"adaptrate"` // rebuild filters for a different sampling rate
+}
+
+// a duration given with units, e.g. 10m, or as a bare number of seconds as in the json config
+type seconds time.Duration
+
+func (d *seconds) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var f float64
+	if err := unmarshal(&f); err == nil {
+		*d = toSeconds(f)
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = seconds(v)
+
+	return nil
+}
+
+func toSeconds(f float64) seconds {
+	return seconds(f * float64(time.Second))
+}
+
+func (d seconds) String() string {
+	return time.Duration(d).String()
+}
+
+// overwrite any stream settings which have been given
+func (c streamConfig) apply(s *impact.Stream) {
+	if c.Name != nil {
+		s.Name = *c.Name
+	}
+	if c.Latitude != nil {
+		s.Latitude = *c.Latitude
+	}
+	if c.Longitude != nil {
+		s.Longitude = *c.Longitude
+	}
+	if c.Rate != nil {
+		s.Rate = *c.Rate
+	}
+	if c.Gain != nil {
+		s.Gain = *c.Gain
+	}
+	if c.Q != nil {
+		s.Q = *c.Q
+	}
+	if c.Level != nil {
+		s.Level = *c.Level
+	}
+	if c.Probation != nil {
+		s.Probation = time.Duration(*c.Probation).Seconds()
+	}
+	if c.Flush != nil {
+		s.FlushInterval = time.Duration(*c.Flush).Seconds()
+	}
+	if c.Detector != nil {
+		s.Detector = c.Detector
+	}
+	if c.Baseline != nil {
+		s.Baseline = *c.Baseline
+	}
+	if c.FullScale != nil {
+		s.FullScale = *c.FullScale
+	}
+	if c.Checks != nil {
+		s.Checks = mergeChecks(c.Checks, s.Checks)
+	}
+	if c.AdaptRate != nil {
+		s.AdaptRate = *c.AdaptRate
+	}
+}
+
+// whether the config file is structured yaml rather than a json map of streams
+func isStructured(config string) bool {
+	switch strings.ToLower(filepath.Ext(config)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+func readConfigFile(config string) (*configFile, error) {
+	b, err := ioutil.ReadFile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var c configFile
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// build the streams, each inheriting the global, network and station defaults in turn
+func (c *configFile) streams() (map[string]*impact.Stream, error) {
+	streams := make(map[string]*impact.Stream)
+	for k, v := range c.Streams {
+		// expect NN_SSS_LL_CCC
+		parts := strings.Split(k, "_")
+		if len(parts) != 4 {
+			return nil, &impact.ConfigError{Key: k, Message: "expected a stream key of the form NN_SSS_LL_CCC"}
+		}
+
+		s := &impact.Stream{}
+		c.Defaults.apply(s)
+		c.Networks[parts[0]].apply(s)
+		c.Stations[parts[0]+"_"+parts[1]].apply(s)
+		v.apply(s)
+
+		streams[k] = s
+	}
+
+	return streams, nil
+}
+
+// check the global settings refer to known flags
+func (c *configFile) checkFlags(flags *flag.FlagSet) []error {
+	var errs []error
+	for _, k := range sortedSettings(c.Global) {
+		if k == "config" || flags.Lookup(k) == nil {
+			errs = append(errs, fmt.Errorf("global: unknown setting: %s", k))
+		}
+	}
+	return errs
+}
+
+// apply the global settings, flags given on the command line take precedence
+func (c *configFile) setFlags(flags *flag.FlagSet) error {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	if errs := c.checkFlags(flags); len(errs) > 0 {
+		return errs[0]
+	}
+	for _, k := range sortedSettings(c.Global) {
+		if given[k] {
+			continue
+		}
+		if err := flags.Set(k, flagValue(flags.Lookup(k), c.Global[k])); err != nil {
+			return fmt.Errorf("global: %s: %s", k, err)
+		}
+	}
+
+	return nil
+}
+
+// a global setting as a flag value, with bare numbers for durations read as seconds as for the stream settings
+func flagValue(f *flag.Flag, v interface{}) string {
+	if g, ok := f.Value.(flag.Getter); ok {
+		if _, ok := g.Get().(time.Duration); ok {
+			switch n := v.(type) {
+			case int:
+				return toSeconds(float64(n)).String()
+			case float64:
+				return toSeconds(n).String()
+			}
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func sortedSettings(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// read the streams from either a structured yaml or a json config file
+func loadStreams(config string) (map[string]*impact.Stream, error) {
+	if !isStructured(config) {
+		return impact.LoadStreams(config)
+	}
+
+	c, err := readConfigFile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.streams()
+}
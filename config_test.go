@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// write a temporary yaml config file, returning its name
+func testConfigFile(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "impact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestConfigStreams(t *testing.T) {
+	config := testConfigFile(t, `
+defaults:
+  q: 0.98
+  gain: 1
+  rate: 50
+  level: 1
+networks:
+  NZ:
+    rate: 100
+    level: 2
+stations:
+  NZ_WEL:
+    name: Wellington
+    level: 3
+streams:
+  NZ_WEL_10_HHZ:
+    gain: 1000
+  NZ_WEL_20_HNZ:
+    rate: 200
+    level: 4
+  NZ_TAU_10_HHZ:
+    name: Taupo
+  AU_CAN_00_BHZ:
+    name: Canberra
+`)
+	defer os.Remove(config)
+
+	c, err := readConfigFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := c.streams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		key   string
+		name  string
+		rate  float64
+		gain  float64
+		q     float64
+		level int32
+	}{
+		// station settings over network over defaults
+		{"NZ_WEL_10_HHZ", "Wellington", 100, 1000, 0.98, 3},
+		// stream settings over everything
+		{"NZ_WEL_20_HNZ", "Wellington", 200, 1, 0.98, 4},
+		// network settings only
+		{"NZ_TAU_10_HHZ", "Taupo", 100, 1, 0.98, 2},
+		// defaults only
+		{"AU_CAN_00_BHZ", "Canberra", 50, 1, 0.98, 1},
+	}
+
+	if len(streams) != len(tests) {
+		t.Fatalf("unexpected number of streams: %d", len(streams))
+	}
+	for _, v := range tests {
+		s, ok := streams[v.key]
+		if !ok {
+			t.Errorf("%s: missing stream", v.key)
+			continue
+		}
+		if s.Name != v.name || s.Rate != v.rate || s.Gain != v.gain || s.Q != v.q || s.Level != v.level {
+			t.Errorf("%s: unexpected settings: %s %g %g %g %d", v.key, s.Name, s.Rate, s.Gain, s.Q, s.Level)
+		}
+	}
+}
+
+func TestConfigStreamKey(t *testing.T) {
+	c := &configFile{Streams: map[string]streamConfig{"NZ_WEL_HHZ": {}}}
+	if _, err := c.streams(); err == nil {
+		t.Error("expected an error for a short stream key")
+	}
+}
+
+func TestConfigUnknownKey(t *testing.T) {
+	config := testConfigFile(t, `
+defaults:
+  q: 0.98
+  gian: 1000
+`)
+	defer os.Remove(config)
+
+	if _, err := readConfigFile(config); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestConfigSetFlags(t *testing.T) {
+	var tests = []struct {
+		args   []string
+		global map[string]interface{}
+		level  int
+		flush  time.Duration
+		ok     bool
+	}{
+		{nil, nil, 1, time.Minute, true},
+		{nil, map[string]interface{}{"level": 2, "flush": "5m"}, 2, 5 * time.Minute, true},
+		// the command line takes precedence
+		{[]string{"-level", "3"}, map[string]interface{}{"level": 2, "flush": "5m"}, 3, 5 * time.Minute, true},
+		{nil, map[string]interface{}{"levle": 2}, 1, time.Minute, false},
+		{nil, map[string]interface{}{"config": "other.yaml"}, 1, time.Minute, false},
+		{nil, map[string]interface{}{"flush": "often"}, 1, time.Minute, false},
+		// bare numbers are seconds, as for the stream settings
+		{nil, map[string]interface{}{"flush": 300}, 1, 5 * time.Minute, true},
+		{nil, map[string]interface{}{"flush": 2.5}, 1, 2500 * time.Millisecond, true},
+		{nil, map[string]interface{}{"level": 3}, 3, time.Minute, true},
+	}
+
+	for i, v := range tests {
+		var level int
+		var flush time.Duration
+		var config string
+
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.IntVar(&level, "level", 1, "")
+		flags.DurationVar(&flush, "flush", time.Minute, "")
+		flags.StringVar(&config, "config", "impact.yaml", "")
+		if err := flags.Parse(v.args); err != nil {
+			t.Fatal(err)
+		}
+
+		c := &configFile{Global: v.global}
+		err := c.setFlags(flags)
+		if (err == nil) != v.ok {
+			t.Errorf("%d: unexpected error result: %v", i, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if level != v.level || flush != v.flush {
+			t.Errorf("%d: unexpected flags: level %d flush %s", i, level, flush)
+		}
+	}
+}
+
+func TestConfigSeconds(t *testing.T) {
+	var tests = []struct {
+		probation string
+		seconds   float64
+		ok        bool
+	}{
+		{"10m", 600, true},
+		{"90s", 90, true},
+		{"600", 600, true},
+		{"2.5", 2.5, true},
+		{"often", 0, false},
+	}
+
+	for _, v := range tests {
+		config := testConfigFile(t, "streams:\n  NZ_WEL_10_HHZ:\n    probation: "+v.probation+"\n    flush: "+v.probation+"\n")
+		defer os.Remove(config)
+
+		c, err := readConfigFile(config)
+		if (err == nil) != v.ok {
+			t.Errorf("%s: unexpected error result: %v", v.probation, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		streams, err := c.streams()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := streams["NZ_WEL_10_HHZ"]; s.Probation != v.seconds || s.FlushInterval != v.seconds {
+			t.Errorf("%s: unexpected periods: %g %g", v.probation, s.Probation, s.FlushInterval)
+		}
+	}
+}
@@ -8,15 +8,19 @@ import (
 	"github.com/GeoNet/mseed"
 	"github.com/GeoNet/slink"
 	"github.com/crowdmob/goamz/aws"
-	"github.com/crowdmob/goamz/sqs"
+	"github.com/crowdmob/goamz/sns"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 func main() {
-	var Q *sqs.Queue
+	var sinks []sink
 
 	// runtime settings
 	var verbose bool
@@ -27,16 +31,24 @@ func main() {
 	// streaming channel information
 	var config string
 	flag.StringVar(&config, "config", "impact.json", "provide a streams config file")
+	var watch time.Duration
+	flag.DurationVar(&watch, "watch", 0, "how often to check the streams config file for changes, zero to disable")
 
 	// amazon queue details
 	var region string
 	flag.StringVar(&region, "region", "", "provide AWS region, overides env variable \"AWS_REGION\"")
 	var queue string
 	flag.StringVar(&queue, "queue", "", "send messages to the SQS queue, overides env variable \"AWS_QUEUE\"")
+	var endpoint string
+	flag.StringVar(&endpoint, "endpoint", "", "send SQS requests to a custom endpoint url, overides env variable \"AWS_IMPACT_ENDPOINT\"")
+	var topic string
+	flag.StringVar(&topic, "topic", "", "publish messages to the SNS topic ARN, overides env variable \"AWS_IMPACT_TOPIC\"")
 	var key string
 	flag.StringVar(&key, "key", "", "AWS access key id, overrides env and credentials file (default profile)")
 	var secret string
 	flag.StringVar(&secret, "secret", "", "AWS secret key id, overrides env and credentials file (default profile)")
+	var refresh time.Duration
+	flag.DurationVar(&refresh, "refresh", 5*time.Minute, "how long before expiry to refresh AWS credentials")
 
 	// seedlink options
 	var netdly int
@@ -49,6 +61,16 @@ func main() {
 	flag.StringVar(&selectors, "selectors", "???", "provide channel selectors")
 	var streams string
 	flag.StringVar(&streams, "streams", "*_*", "provide streams")
+	var auto bool
+	flag.BoolVar(&auto, "auto", false, "request the configured streams, rather than using the streams and selectors")
+	var missing time.Duration
+	flag.DurationVar(&missing, "missing", 10*time.Minute, "warn about configured streams with no data after this long, zero to disable")
+
+	// seedlink state and shutdown
+	var statefile string
+	flag.StringVar(&statefile, "state", "", "save and recover the SeedLink stream state in this file")
+	var shutdown time.Duration
+	flag.DurationVar(&shutdown, "shutdown", 30*time.Second, "how long to wait for pending messages when shutting down")
 
 	// heartbeat flush interval
 	var flush time.Duration
@@ -60,6 +82,62 @@ func main() {
 	var level int
 	flag.IntVar(&level, "level", 2, "noise threshold level")
 
+	// earthquake detection, to avoid jailing a stream during an event
+	var sta float64
+	flag.Float64Var(&sta, "sta", 0, "event detector short term window in seconds, zero to disable")
+	var lta float64
+	flag.Float64Var(&lta, "lta", 30, "event detector long term window in seconds")
+	var on float64
+	flag.Float64Var(&on, "on", 4, "event detector trigger ratio")
+	var off float64
+	flag.Float64Var(&off, "off", 1.5, "event detector release ratio")
+	var kurtosis float64
+	flag.Float64Var(&kurtosis, "kurtosis", 0, "event detector minimum kurtosis, zero to disable")
+
+	// busy time of week background
+	var baseline float64
+	flag.Float64Var(&baseline, "baseline", 0, "weeks of history to learn an hour of week background level over, zero to disable")
+
+	// data quality checks
+	var flat float64
+	flag.Float64Var(&flat, "flat", 0, "seconds of unchanging counts for a dead channel, zero to disable")
+	var spike float64
+	flag.Float64Var(&spike, "spike", 0, "ratio of a single sample step to the typical step for a spike, zero to disable")
+	var offset float64
+	flag.Float64Var(&offset, "offset", 0, "largest running mean in counts before the DC offset is a problem, zero to disable")
+	var mass float64
+	flag.Float64Var(&mass, "mass", 0, "largest running mean as a fraction of full scale before the mass is off centre, zero to disable")
+	var timing float64
+	flag.Float64Var(&timing, "timing", 0, "lowest blockette 1001 timing quality percentage before the clock is a problem, zero to disable")
+	var adapt bool
+	flag.BoolVar(&adapt, "adapt", false, "rebuild the filters for a different record sampling rate rather than rejecting the data")
+	var suppress bool
+	flag.BoolVar(&suppress, "suppress", false, "suppress messages from streams with data quality problems rather than flagging them")
+
+	// noise state kept across restarts
+	var snapshot string
+	flag.StringVar(&snapshot, "snapshot", "", "save and restore the stream noise state in this file")
+	var every time.Duration
+	flag.DurationVar(&every, "snapshot-interval", time.Minute, "how often to save the stream noise state")
+	var oldest time.Duration
+	flag.DurationVar(&oldest, "snapshot-age", time.Hour, "ignore a noise state snapshot older than this, zero to always restore")
+
+	// status server
+	var listen string
+	flag.StringVar(&listen, "http", "", "serve health and stream status on this address, e.g. \":8080\"")
+	var age time.Duration
+	flag.DurationVar(&age, "health", 5*time.Minute, "how old the last packet can be and still be healthy")
+	var manage string
+	flag.StringVar(&manage, "admin", "", "serve manual stream jail and release requests on this address, e.g. \"localhost:8081\"")
+
+	// data latency and gap monitoring
+	var stale time.Duration
+	flag.DurationVar(&stale, "stale", 0, "send a stale status message if stream data is older than this, zero to disable")
+	var gaps int
+	flag.IntVar(&gaps, "gaps", 0, "send a gappy status message if there are more breaks than this in the gap window, zero to disable")
+	var window time.Duration
+	flag.DurationVar(&window, "window", 10*time.Minute, "gap monitoring window")
+
 	// problem sending messages
 	var resends int
 	flag.IntVar(&resends, "resends", 6, "how many times to try and resend a message")
@@ -68,6 +146,47 @@ func main() {
 
 	flag.Parse()
 
+	// just check the streams config, e.g. before deploying
+	if flag.Arg(0) == "validate" {
+		if flag.NArg() > 1 {
+			config = flag.Arg(1)
+		}
+		if !validateStreams(config) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// manually jail, release or clear a stream in a running process
+	switch flag.Arg(0) {
+	case "jail", "release", "clear":
+		if manage == "" || flag.NArg() < 2 {
+			log.Fatalf("usage: -admin <address> %s <stream> [duration]\n", flag.Arg(0))
+		}
+		var d time.Duration
+		if flag.Arg(0) != "clear" {
+			var err error
+			if d, err = time.ParseDuration(flag.Arg(2)); err != nil {
+				log.Fatalf("invalid duration: %s\n", err)
+			}
+		}
+		if err := requestOverride(manage, flag.Arg(0), flag.Arg(1), d); err != nil {
+			log.Fatalf("unable to %s stream: %s\n", flag.Arg(0), err)
+		}
+		return
+	}
+
+	// a structured config file may also hold the global settings
+	if isStructured(config) {
+		c, err := readConfigFile(config)
+		if err != nil {
+			log.Fatalf("unable to read config file: %s [%s]\n", err, config)
+		}
+		if err := c.setFlags(flag.CommandLine); err != nil {
+			log.Fatalf("unable to apply config file settings: %s [%s]\n", err, config)
+		}
+	}
+
 	if !dryrun {
 		if region == "" {
 			region = os.Getenv("AWS_IMPACT_REGION")
@@ -78,38 +197,66 @@ func main() {
 
 		if queue == "" {
 			queue = os.Getenv("AWS_IMPACT_QUEUE")
-			if queue == "" {
-				log.Fatalf("unable to find queue in environment or command line [AWS_IMPACT_QUEUE]")
-			}
+		}
+		if topic == "" {
+			topic = os.Getenv("AWS_IMPACT_TOPIC")
+		}
+		if queue == "" && topic == "" {
+			log.Fatalf("unable to find queue or topic in environment or command line [AWS_IMPACT_QUEUE/AWS_IMPACT_TOPIC]")
+		}
+
+		if endpoint == "" {
+			endpoint = os.Getenv("AWS_IMPACT_ENDPOINT")
 		}
 
 		// configure amazon ...
-		R := aws.GetRegion(region)
-		// fall through to env then credentials file
-		A, err := aws.GetAuth(key, secret, "", time.Now().Add(30*time.Minute))
+		R, err := amazonRegion(region, endpoint, topic != "")
+		if err != nil {
+			log.Fatalf("%s\n", err)
+		}
+		// fall through to env, instance role, then credentials file, refreshing before expiry
+		C, err := aws.NewCredentials(key, secret, refresh)
 		if err != nil {
 			log.Fatalf("unable to get amazon auth: %s\n", err)
 		}
 
-		S := sqs.New(A, R)
-		Q, err = S.GetQueue(queue)
-		if err != nil {
-			log.Fatalf("unable to get amazon queue: %s [%s/%s]\n", err, queue, region)
+		if queue != "" {
+			Q, err := newQueueSink(C, R, endpoint, queue)
+			if err != nil {
+				log.Fatalf("unable to get amazon queue: %s [%s/%s]\n", err, queue, region)
+			}
+			sinks = append(sinks, Q)
+		}
+		if topic != "" {
+			N, err := sns.NewWithCredentials(C, R)
+			if err != nil {
+				log.Fatalf("unable to get amazon auth: %s\n", err)
+			}
+			sinks = append(sinks, &topicSink{sns: N, topic: topic})
 		}
 	}
 
-	// load json file
-	state := impact.LoadStreams(config)
-	if state == nil {
-		log.Println("unable to parse config file: ", config)
-		os.Exit(1)
+	defaults := streamDefaults{baseline: baseline, adapt: adapt}
+	if sta > 0 {
+		defaults.detector = &impact.Detector{Short: sta, Long: lta, On: on, Off: off, Kurtosis: kurtosis}
+		if errs := defaults.detector.Validate(); len(errs) > 0 {
+			log.Fatalf("invalid event detector settings: %s\n", errs[0])
+		}
 	}
-        
-	// initial stream setup
-	for s := range state {
-		_, err := state[s].Init(s, probation, (int32)(level))
-		if err != nil {
-			log.Fatalf("unable to get initial state: %s\n", err)
+	if flat > 0 || spike > 0 || offset > 0 || mass > 0 || timing > 0 {
+		defaults.checks = &impact.Checks{Flat: flat, Spike: spike, Offset: offset, Mass: mass, Timing: timing, Suppress: suppress}
+	}
+
+	// load json file and initial stream setup
+	reg, err := newRegistry(config, probation, (int32)(level), defaults)
+	if err != nil {
+		log.Fatalf("unable to get initial state: %s [%s]\n", err, config)
+	}
+
+	// pick up any jailed streams from before a restart
+	if snapshot != "" {
+		if err := reg.restore(snapshot, oldest); err != nil {
+			log.Printf("unable to restore noise snapshot: %s [%s]\n", err, snapshot)
 		}
 	}
 
@@ -119,21 +266,64 @@ func main() {
 		server = flag.Arg(0)
 	}
 
-	// initial seedlink handle
-	slconn := slink.NewSLCD()
-	defer slink.FreeSLCD(slconn)
+	link := &seedlink{
+		server:    server,
+		netdly:    netdly,
+		netto:     netto,
+		keepalive: keepalive,
+		streams:   streams,
+		selectors: selectors,
+		statefile: statefile,
+	}
 
-	// seedlink settings
-	slconn.SetNetDly(netdly)
-	slconn.SetNetTo(netto)
-	slconn.SetKeepAlive(keepalive)
+	// per-stream status, metrics and latency monitoring
+	st := newStatus(age)
+	mt := newMetrics()
+	mon := newMonitor(stale, gaps, window)
 
-	// conection
-	slconn.SetSLAddr(server)
-	defer slconn.Disconnect()
+	// reload the streams on a hangup, or if the config file changes
+	reload := func() {
+		before := reg.names()
+		removed, err := reg.reload()
+		for _, k := range removed {
+			// removed streams are no longer processed, so forget them
+			st.removeStream(k)
+			mt.removeStream(k)
+			mon.remove(k)
+		}
+		if err != nil {
+			log.Printf("unable to reload config file: %s\n", err)
+			return
+		}
+		// seedlink needs a new connection to request more streams, removed streams are just ignored
+		if added := addedStreams(before, reg.names()); auto && len(added) > 0 {
+			log.Printf("streams added, reconnecting: %s\n", strings.Join(added, " "))
+			link.reconnect()
+		}
+	}
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			log.Printf("reloading config file: %s\n", config)
+			reload()
+		}
+	}()
+	if watch > 0 {
+		go watchConfig(config, watch, reload)
+	}
+
+	// stop collecting on a signal, a second signal forces an exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("received %s, shutting down\n", sig)
+		link.terminate()
 
-	// configure streams selectors to recover
-	slconn.ParseStreamList(streams, selectors)
+		sig = <-signals
+		log.Fatalf("received %s, exiting\n", sig)
+	}()
 
 	// make space for miniseed blocks
 	msr := mseed.NewMSRecord()
@@ -142,11 +332,23 @@ func main() {
 	// fixup stream code for messaging
 	replace := strings.NewReplacer("_", ".")
 
+	// running state
+	if listen != "" {
+		mux := http.NewServeMux()
+		st.register(mux)
+		mt.register(mux)
+		go func() {
+			log.Fatal(http.ListenAndServe(listen, mux))
+		}()
+	}
+
 	// output channel
-	result := make(chan impact.Message)
+	result := make(chan output)
+	done := make(chan struct{})
 	go func() {
-		for m := range result {
-			mm, err := json.Marshal(m)
+		defer close(done)
+		for o := range result {
+			mm, err := json.Marshal(o.message)
 			if err != nil {
 				log.Printf("unable to marshal message: %s\n", err)
 				continue
@@ -154,62 +356,194 @@ func main() {
 			if verbose {
 				fmt.Println(string(mm))
 			}
-			if !dryrun {
-				for n := 0; n < resends; n++ {
-					_, err := Q.SendMessage(string(mm))
-					if err != nil {
-						log.Printf("unable to send message [#%d/%d]: %s\n", n+1, resends, err)
-						log.Printf("sleeping %s\n", wait)
-						time.Sleep(wait)
-					}
-					break
+			for _, s := range sinks {
+				n, err := deliver(s, string(mm), o.attributes(), resends, wait)
+				if err != nil {
+					log.Printf("unable to deliver message to %s: %s\n", s, err)
 				}
+				st.setSink(s.String(), err)
+				mt.delivery(s.String(), n, err, o.end)
 			}
 		}
 	}()
 
-	for {
-		// recover packet ...
-		p, rc := slconn.Collect()
-		if rc != slink.SLPACKET {
-			break
-		}
-		// just in case we're shutting down
-		if p.PacketType() != slink.SLDATA {
-			continue
-		}
+	// stream latency and gap monitoring
+	var monitoring sync.WaitGroup
+	stop := make(chan struct{})
+	if stale > 0 || gaps > 0 {
+		monitoring.Add(1)
+		go func() {
+			defer monitoring.Done()
+			mon.run(result, stop)
+		}()
+	}
 
-		// decode miniseed block
-		buf := p.GetMSRecord()
-		msr.Unpack(buf, 512, 1, 0)
+	// keep the noise state in case of a restart
+	if snapshot != "" && every > 0 {
+		monitoring.Add(1)
+		go func() {
+			defer monitoring.Done()
+			reg.snapshots(snapshot, every, stop)
+		}()
+	}
 
-		// what to send
-		source := strings.TrimRight(msr.Network()+"."+msr.Station(), "\u0000")
+	// manual jail and release requests, kept apart from the status server
+	if manage != "" {
+		mux := http.NewServeMux()
+		(&admin{reg: reg, snapshot: snapshot}).register(mux)
+		go func() {
+			log.Fatal(http.ListenAndServe(manage, mux))
+		}()
+	}
 
-		// get lookup key
-		srcname := msr.SrcName(0)
-		stream, ok := state[srcname]
-		if ok == false {
-			continue
-		}
+	// warn about configured streams which have never been received
+	if auto && missing > 0 {
+		go func() {
+			warned := make(map[string]bool)
+			for range time.Tick(missing) {
+				received := st.snapshot()
+				for _, k := range reg.names() {
+					if _, ok := received[k]; ok || warned[k] {
+						continue
+					}
+					log.Printf("[%s] no data received for configured stream\n", k)
+					warned[k] = true
+				}
+			}
+		}()
+	}
+
+	// the last rejected record rate of each stream, only used by the collect loop
+	mismatched := make(map[string]float64)
+
+	// process each block into any messages to send, the caller sends them once the streams are unlocked
+	process := func(stream *impact.Stream, source, srcname string) []output {
+		mt.packet(srcname)
 
 		// recover amplitude samples
 		samples, err := msr.DataSamples()
 		if err != nil {
 			log.Printf("data sample problem! %s\n", err)
-			continue
+			mt.decodeError(srcname)
+			return nil
 		}
 
-		// process each block into a message
-		message, err := stream.ProcessSamples(replace.Replace(source), srcname, msr.Starttime(), samples)
+		message, err := stream.ProcessRecord(source, srcname, recordHeader(msr), msr.Starttime(), samples)
 		if err != nil {
-			log.Printf("data processing problem! %s\n", err)
-			continue
+			if e, ok := err.(*impact.RateError); ok {
+				// every record will be rejected, so only report a change of rate
+				if mismatched[srcname] != e.Found {
+					log.Printf("[%s] rejecting records: %s\n", srcname, err)
+					mismatched[srcname] = e.Found
+				}
+				mt.rateMismatch(srcname)
+			} else {
+				log.Printf("[%s] data processing problem! %s\n", srcname, err)
+			}
+			mt.processingError(srcname)
+			return nil
+		}
+		delete(mismatched, srcname)
+
+		// a heartbeat if the intensity hasn't changed
+		kind := changeMessage
+		if stream.MMI() == message.MMI {
+			kind = heartbeatMessage
 		}
 
 		// should we send a message
+		var outputs []output
 		if stream.Flush(flush, message.MMI) {
-			result <- message
+			outputs = append(outputs, output{message: message, kind: kind, end: msr.Endtime()})
+		}
+
+		// let downstream know when a stream is jailed or released
+		if jail, ok := stream.JailMessage(message); ok {
+			log.Printf("[%s] stream %s: mmi %d\n", srcname, jail.Reason, jail.MMI)
+			outputs = append(outputs, output{message: jail, kind: statusMessage, end: msr.Endtime()})
+		}
+
+		current := stream.Status()
+		st.setStream(srcname, current)
+		mt.stream(srcname, message.MMI, current)
+		mon.update(srcname, message, msr.Endtime(), current)
+
+		return outputs
+	}
+
+	for {
+		// request either the configured streams or the given stream list
+		var selection map[string]string
+		if auto {
+			selection = reg.selection()
 		}
+		slconn, err := link.connect(selection)
+		if err != nil {
+			log.Fatalf("unable to configure seedlink: %s\n", err)
+		}
+		if slconn == nil {
+			break
+		}
+
+		for {
+			// recover packet ...
+			p, rc := slconn.Collect()
+			if rc != slink.SLPACKET {
+				st.setConnected(false)
+				break
+			}
+			st.setConnected(slconn.Connected())
+
+			// just in case we're shutting down
+			if p.PacketType() != slink.SLDATA {
+				continue
+			}
+
+			// decode miniseed block
+			buf := p.GetMSRecord()
+			msr.Unpack(buf, 512, 1, 0)
+
+			// what to send
+			source := strings.TrimRight(msr.Network()+"."+msr.Station(), "\u0000")
+
+			// get lookup key
+			srcname := msr.SrcName(0)
+
+			// the streams may be reloaded at any time
+			var outputs []output
+			reg.Lock()
+			if stream, ok := reg.streams[srcname]; ok {
+				outputs = process(stream, replace.Replace(source), srcname)
+			}
+			reg.Unlock()
+
+			// a slow sink mustn't hold up reloads, snapshots or the admin handler
+			for _, o := range outputs {
+				result <- o
+			}
+		}
+
+		link.close(slconn)
+
+		if !link.restarting() {
+			break
+		}
+	}
+
+	// stop any status messages and wait for pending messages to be sent
+	close(stop)
+	monitoring.Wait()
+
+	if snapshot != "" {
+		if err := reg.save(snapshot); err != nil {
+			log.Printf("unable to save noise snapshot: %s [%s]\n", err, snapshot)
+		}
+	}
+	close(result)
+
+	select {
+	case <-done:
+	case <-time.After(shutdown):
+		log.Printf("gave up waiting for pending messages after %s\n", shutdown)
 	}
 }
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"github.com/GeoNet/impact"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// latency histogram bucket upper bounds, in seconds
+var latencyBuckets = []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300}
+
+// a set of values keyed by a single label value
+type labelled map[string]float64
+
+// a cumulative histogram
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// prometheus style process metrics
+type metrics struct {
+	sync.Mutex
+
+	packets    labelled // packets received per stream
+	decode     labelled // data sample decoding errors per stream
+	processing labelled // sample processing errors per stream
+	resets     labelled // filter resets per stream
+	clips      labelled // packets with clipped samples per stream
+	mismatches labelled // records with an unexpected sampling rate per stream
+	gaps       labelled // data gaps per stream
+	overlaps   labelled // data overlaps per stream
+	delay      labelled // data latency per stream
+	mmi        labelled // current intensity per stream
+	timing     labelled // last known timing quality per stream
+	jailed     labelled // whether each stream is jailed
+
+	sent    labelled // messages sent per sink
+	failed  labelled // messages which could not be sent per sink
+	retried labelled // message resends per sink
+
+	latency map[string]*histogram // record end time to message send per sink
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		packets:    make(labelled),
+		decode:     make(labelled),
+		processing: make(labelled),
+		resets:     make(labelled),
+		clips:      make(labelled),
+		mismatches: make(labelled),
+		gaps:       make(labelled),
+		overlaps:   make(labelled),
+		delay:      make(labelled),
+		mmi:        make(labelled),
+		timing:     make(labelled),
+		jailed:     make(labelled),
+		sent:       make(labelled),
+		failed:     make(labelled),
+		retried:    make(labelled),
+		latency:    make(map[string]*histogram),
+	}
+}
+
+func (m *metrics) packet(srcname string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.packets[srcname]++
+}
+
+func (m *metrics) decodeError(srcname string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.decode[srcname]++
+}
+
+func (m *metrics) processingError(srcname string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.processing[srcname]++
+}
+
+func (m *metrics) rateMismatch(srcname string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.mismatches[srcname]++
+}
+
+// note the stream state after processing a packet
+func (m *metrics) stream(srcname string, mmi int32, status impact.Status) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.mmi[srcname] = float64(mmi)
+	m.resets[srcname] = float64(status.Resets)
+	m.clips[srcname] = float64(status.Clips)
+	m.gaps[srcname] = float64(status.Gaps)
+	m.overlaps[srcname] = float64(status.Overlaps)
+	m.delay[srcname] = time.Since(status.Last).Seconds()
+	if status.Timing >= 0 {
+		m.timing[srcname] = float64(status.Timing)
+	}
+	if status.Jailed {
+		m.jailed[srcname] = 1
+	} else {
+		m.jailed[srcname] = 0
+	}
+}
+
+// forget a stream which is no longer configured
+func (m *metrics) removeStream(srcname string) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, l := range []labelled{m.packets, m.decode, m.processing, m.resets, m.clips, m.mismatches, m.gaps, m.overlaps, m.delay, m.mmi, m.timing, m.jailed} {
+		delete(l, srcname)
+	}
+}
+
+// note a message delivery, with the number of attempts made and the end time of the triggering record
+func (m *metrics) delivery(sink string, attempts int, err error, end time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	if attempts > 1 {
+		m.retried[sink] += float64(attempts - 1)
+	}
+	if err != nil {
+		m.failed[sink]++
+		return
+	}
+	m.sent[sink]++
+
+	h, ok := m.latency[sink]
+	if !ok {
+		h = &histogram{buckets: latencyBuckets, counts: make([]uint64, len(latencyBuckets))}
+		m.latency[sink] = h
+	}
+	h.observe(time.Since(end).Seconds())
+}
+
+// write the metrics in the prometheus text exposition format
+func (m *metrics) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+
+	writeLabelled(w, "slimpact_packets_total", "counter", "SeedLink packets received.", "stream", m.packets)
+	writeLabelled(w, "slimpact_decode_errors_total", "counter", "Data sample decoding errors.", "stream", m.decode)
+	writeLabelled(w, "slimpact_processing_errors_total", "counter", "Sample processing errors.", "stream", m.processing)
+	writeLabelled(w, "slimpact_filter_resets_total", "counter", "Filter resets after a break in the data.", "stream", m.resets)
+	writeLabelled(w, "slimpact_clipped_total", "counter", "Packets with clipped samples.", "stream", m.clips)
+	writeLabelled(w, "slimpact_rate_mismatches_total", "counter", "Records rejected with an unexpected sampling rate.", "stream", m.mismatches)
+	writeLabelled(w, "slimpact_gaps_total", "counter", "Breaks with missing data.", "stream", m.gaps)
+	writeLabelled(w, "slimpact_overlaps_total", "counter", "Breaks with repeated data.", "stream", m.overlaps)
+	writeLabelled(w, "slimpact_data_latency_seconds", "gauge", "Delay from the record end time to the record being processed.", "stream", m.delay)
+	writeLabelled(w, "slimpact_mmi", "gauge", "Current intensity.", "stream", m.mmi)
+	writeLabelled(w, "slimpact_timing_quality", "gauge", "Blockette 1001 timing quality percentage.", "stream", m.timing)
+
+	var jailed float64
+	for _, v := range m.jailed {
+		jailed += v
+	}
+	fmt.Fprintf(w, "# HELP slimpact_jailed_streams Streams jailed as too noisy.\n")
+	fmt.Fprintf(w, "# TYPE slimpact_jailed_streams gauge\n")
+	fmt.Fprintf(w, "slimpact_jailed_streams %s\n", formatValue(jailed))
+
+	writeLabelled(w, "slimpact_messages_sent_total", "counter", "Messages sent.", "sink", m.sent)
+	writeLabelled(w, "slimpact_messages_failed_total", "counter", "Messages which could not be sent.", "sink", m.failed)
+	writeLabelled(w, "slimpact_messages_retried_total", "counter", "Message resends.", "sink", m.retried)
+
+	fmt.Fprintf(w, "# HELP slimpact_latency_seconds Delay from the record end time to the message being sent.\n")
+	fmt.Fprintf(w, "# TYPE slimpact_latency_seconds histogram\n")
+	for _, k := range sortedKeys(m.latency) {
+		h := m.latency[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "slimpact_latency_seconds_bucket{sink=%q,le=%q} %d\n", k, formatValue(b), h.counts[i])
+		}
+		fmt.Fprintf(w, "slimpact_latency_seconds_bucket{sink=%q,le=\"+Inf\"} %d\n", k, h.count)
+		fmt.Fprintf(w, "slimpact_latency_seconds_sum{sink=%q} %s\n", k, formatValue(h.sum))
+		fmt.Fprintf(w, "slimpact_latency_seconds_count{sink=%q} %d\n", k, h.count)
+	}
+}
+
+func (m *metrics) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.write(w)
+}
+
+// add the metrics handler to an http server
+func (m *metrics) register(mux *http.ServeMux) {
+	mux.HandleFunc("/metrics", m.handleMetrics)
+}
+
+func writeLabelled(w io.Writer, name, kind, help, label string, values labelled) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", name, label, k, formatValue(values[k]))
+	}
+}
+
+func sortedKeys(h map[string]*histogram) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
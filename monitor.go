@@ -0,0 +1,141 @@
+package main
+
+import (
+	"github.com/GeoNet/impact"
+	"sync"
+	"time"
+)
+
+// how often to check streams for latency and gaps
+const monitorInterval = 10 * time.Second
+
+// stream quality states
+const (
+	measuredQuality = "measured" // the stream is running normally
+	staleQuality    = "stale"    // no recent data
+	gappyQuality    = "gappy"    // too many recent breaks
+)
+
+// per-stream data latency and gap tracking
+type monitored struct {
+	message impact.Message // most recent message, for the stream details in status messages
+	end     time.Time      // end of the most recent record
+	breaks  int64          // gap and overlap count
+	recent  []time.Time    // times of recent gaps and overlaps
+	quality string         // the current quality state
+}
+
+// watch streams for data latency and gaps, sending status messages when these change
+type monitor struct {
+	sync.Mutex
+
+	stale  time.Duration // data latency threshold
+	gaps   int           // the number of breaks allowed
+	window time.Duration // the window for counting breaks
+
+	streams map[string]*monitored
+}
+
+func newMonitor(stale time.Duration, gaps int, window time.Duration) *monitor {
+	return &monitor{
+		stale:   stale,
+		gaps:    gaps,
+		window:  window,
+		streams: make(map[string]*monitored),
+	}
+}
+
+// note a processed record and the resulting stream state
+func (m *monitor) update(srcname string, message impact.Message, end time.Time, status impact.Status) {
+	m.Lock()
+	defer m.Unlock()
+
+	s, ok := m.streams[srcname]
+	if !ok {
+		s = &monitored{quality: measuredQuality}
+		m.streams[srcname] = s
+	}
+
+	if breaks := status.Gaps + status.Overlaps; ok && breaks > s.breaks {
+		s.recent = append(s.recent, time.Now())
+	}
+
+	s.message = message
+	s.end = end
+	s.breaks = status.Gaps + status.Overlaps
+}
+
+// stop watching a stream which is no longer configured
+func (m *monitor) remove(srcname string) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.streams, srcname)
+}
+
+// find any streams which have changed quality state
+func (m *monitor) check(now time.Time) []output {
+	m.Lock()
+	defer m.Unlock()
+
+	var changes []output
+	for _, s := range m.streams {
+		// only keep breaks within the window
+		var recent []time.Time
+		for _, t := range s.recent {
+			if now.Sub(t) < m.window {
+				recent = append(recent, t)
+			}
+		}
+		s.recent = recent
+
+		quality := measuredQuality
+		switch {
+		case m.stale > 0 && now.Sub(s.end) > m.stale:
+			quality = staleQuality
+		case m.gaps > 0 && len(s.recent) > m.gaps:
+			quality = gappyQuality
+		}
+
+		if quality == s.quality {
+			continue
+		}
+		s.quality = quality
+
+		// only the stream details and intensity carry over from the last data message
+		message := impact.Message{
+			Source:    s.message.Source,
+			Quality:   quality,
+			Latitude:  s.message.Latitude,
+			Longitude: s.message.Longitude,
+			Time:      s.end,
+			MMI:       s.message.MMI,
+			Comment:   s.message.Comment,
+		}
+
+		changes = append(changes, output{message: message, kind: statusMessage, end: s.end})
+	}
+
+	return changes
+}
+
+// periodically check the streams, sending any status messages until stopped
+func (m *monitor) run(result chan<- output, stop <-chan struct{}) {
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, o := range m.check(now) {
+				select {
+				case result <- o:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
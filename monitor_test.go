@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/GeoNet/impact"
+	"testing"
+	"time"
+)
+
+func TestMonitorStatusMessage(t *testing.T) {
+	m := newMonitor(time.Minute, 0, time.Hour)
+
+	end := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	message := impact.Message{
+		Source:    "NZ.WEL",
+		Quality:   "suspect",
+		Latitude:  -41.28,
+		Longitude: 174.77,
+		Time:      end,
+		MMI:       4,
+		Comment:   "Wellington",
+		Reason:    "spiky",
+		Duration:  90.0,
+		Ratio:     2.5,
+		Bound:     "lower",
+	}
+	m.update("NZ_WEL_10_HHZ", message, end, impact.Status{})
+
+	changes := m.check(end.Add(30 * time.Second))
+	if len(changes) != 0 {
+		t.Fatalf("unexpected status messages: %v", changes)
+	}
+
+	changes = m.check(end.Add(2 * time.Minute))
+	if len(changes) != 1 {
+		t.Fatalf("expected a stale status message: %v", changes)
+	}
+
+	expected := impact.Message{
+		Source:    "NZ.WEL",
+		Quality:   staleQuality,
+		Latitude:  -41.28,
+		Longitude: 174.77,
+		Time:      end,
+		MMI:       4,
+		Comment:   "Wellington",
+	}
+	if s := changes[0].message; s != expected {
+		t.Errorf("invalid status message: %+v", s)
+	}
+	if changes[0].kind != statusMessage {
+		t.Errorf("invalid status message kind: %v", changes[0].kind)
+	}
+}
+
+func TestMonitorRemove(t *testing.T) {
+	m := newMonitor(time.Minute, 0, time.Hour)
+
+	end := time.Date(2015, 8, 17, 0, 0, 0, 0, time.UTC)
+	m.update("NZ_WEL_10_HHZ", impact.Message{Source: "NZ.WEL"}, end, impact.Status{})
+	m.remove("NZ_WEL_10_HHZ")
+
+	if changes := m.check(end.Add(2 * time.Minute)); len(changes) != 0 {
+		t.Errorf("unexpected status messages for a removed stream: %v", changes)
+	}
+}
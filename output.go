@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/GeoNet/impact"
+	"strconv"
+	"time"
+)
+
+// message schema version, sent as an attribute to allow consumers to route on it
+const schemaVersion = "1"
+
+// message types
+const (
+	changeMessage    = "change"    // a new intensity value
+	heartbeatMessage = "heartbeat" // a repeated intensity value
+	statusMessage    = "status"    // a change in stream state
+)
+
+// message attributes holding numbers, rather than strings
+var numericAttributes = map[string]bool{
+	"MMI":     true,
+	"version": true,
+}
+
+// a message ready to be sent along with any routing details
+type output struct {
+	message impact.Message
+	kind    string
+	end     time.Time // end time of the triggering record
+}
+
+// message attributes used for subscription filters and routing
+func (o output) attributes() map[string]string {
+	attributes := map[string]string{
+		"source":  o.message.Source,
+		"MMI":     strconv.Itoa(int(o.message.MMI)),
+		"quality": o.message.Quality,
+		"type":    o.kind,
+		"version": schemaVersion,
+	}
+	// attribute values can't be empty
+	if o.message.Reason != "" {
+		attributes["reason"] = o.message.Reason
+	}
+	return attributes
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/GeoNet/impact"
+	"github.com/GeoNet/mseed"
+)
+
+// the record header details needed for processing
+func recordHeader(msr *mseed.MSRecord) impact.Header {
+	act, dq := msr.ActFlags(), msr.DQFlags()
+
+	return impact.Header{
+		Quality:     msr.Dataquality(),
+		Calibration: act&mseed.ActCalibration != 0,
+		Clipped:     dq&(mseed.DQSaturation|mseed.DQClipping) != 0,
+		Glitches:    dq&(mseed.DQSpikes|mseed.DQGlitches) != 0,
+		Timing:      dq&mseed.DQTimeQuestion != 0,
+
+		TimingQuality: msr.TimingQuality(),
+
+		Rate: float64(msr.Samprate()),
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/GeoNet/impact"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the running streams, shared between the collect loop and any configuration reloads
+type registry struct {
+	sync.Mutex
+
+	config    string         // stream configuration file
+	probation time.Duration  // noise probation period
+	level     int32          // noise threshold level
+	defaults  streamDefaults // settings for streams without their own
+
+	streams map[string]*impact.Stream
+	loaded  bool // whether the initial configuration has been loaded
+
+	saving sync.Mutex // snapshots may be saved by the ticker, the admin handler and on shutdown
+}
+
+// stream settings used unless given in the configuration
+type streamDefaults struct {
+	detector *impact.Detector // event detection, nil to disable
+	checks   *impact.Checks   // data quality checks, nil to disable
+	baseline float64          // weeks of background history, zero to disable
+	adapt    bool             // rebuild filters for a different sampling rate
+}
+
+func newRegistry(config string, probation time.Duration, level int32, defaults streamDefaults) (*registry, error) {
+	r := &registry{
+		config:    config,
+		probation: probation,
+		level:     level,
+		defaults:  defaults,
+		streams:   make(map[string]*impact.Stream),
+	}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// check the stream configuration file, reporting all the problems found
+func validateStreams(config string) bool {
+	errs := checkConfig(config)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", config, err)
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problem(s) found\n", config, len(errs))
+		return false
+	}
+
+	fmt.Printf("%s: ok\n", config)
+	return true
+}
+
+// find all the problems with either a structured yaml or a json config file
+func checkConfig(config string) []error {
+	if !isStructured(config) {
+		f, err := os.Open(config)
+		if err != nil {
+			return []error{err}
+		}
+		defer f.Close()
+
+		return impact.ValidateConfig(f)
+	}
+
+	c, err := readConfigFile(config)
+	if err != nil {
+		return []error{err}
+	}
+	errs := c.checkFlags(flag.CommandLine)
+
+	streams, err := c.streams()
+	if err != nil {
+		return append(errs, err)
+	}
+
+	keys := make([]string, 0, len(streams))
+	for k := range streams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		errs = append(errs, streams[k].Validate(k)...)
+	}
+
+	return errs
+}
+
+// load the configuration file, adding and removing streams as needed, existing streams are
+// updated but keep their running filter and noise state unless the filters need rebuilding,
+// the names of any removed streams are returned
+func (r *registry) reload() ([]string, error) {
+	streams, err := loadStreams(r.config)
+	if err != nil {
+		return nil, err
+	}
+
+	// check everything before changing anything
+	for k, s := range streams {
+		if s.Detector == nil {
+			s.Detector = r.defaults.detector
+		}
+		s.Checks = mergeChecks(s.Checks, r.defaults.checks)
+		if s.Baseline == 0.0 {
+			s.Baseline = r.defaults.baseline
+		}
+		if r.defaults.adapt {
+			s.AdaptRate = true
+		}
+		// an invalid detector would be ignored, or worse never release, so refuse it
+		if d := s.Detector; d != nil {
+			if errs := d.Validate(); len(errs) > 0 {
+				return nil, fmt.Errorf("%s: detector: %s", k, errs[0])
+			}
+		}
+		if _, err := s.Init(k, r.probation, r.level); err != nil {
+			return nil, fmt.Errorf("%s: %s", k, err)
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	var removed []string
+	for k := range r.streams {
+		if _, ok := streams[k]; !ok {
+			log.Printf("[%s] removing stream\n", k)
+			delete(r.streams, k)
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+
+	for k, s := range streams {
+		stream, ok := r.streams[k]
+		if !ok {
+			if r.loaded {
+				log.Printf("[%s] adding stream\n", k)
+			}
+			r.streams[k] = s
+			continue
+		}
+		changed, err := stream.Update(k, s)
+		if err != nil {
+			return removed, fmt.Errorf("%s: %s", k, err)
+		}
+		if changed {
+			log.Printf("[%s] updating stream filters\n", k)
+		}
+	}
+	r.loaded = true
+
+	return removed, nil
+}
+
+// the configured stream names
+func (r *registry) names() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	var names []string
+	for k := range r.streams {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// stream data quality checks over any defaults, unset (zero) checks keep the default setting
+func mergeChecks(c, defaults *impact.Checks) *impact.Checks {
+	switch {
+	case c == nil:
+		return defaults
+	case defaults == nil:
+		return c
+	}
+
+	// a copy, as the defaults are shared
+	merged := *defaults
+	if c.Flat != 0.0 {
+		merged.Flat = c.Flat
+	}
+	if c.Spike != 0.0 {
+		merged.Spike = c.Spike
+	}
+	if c.Offset != 0.0 {
+		merged.Offset = c.Offset
+	}
+	if c.Mass != 0.0 {
+		merged.Mass = c.Mass
+	}
+	if c.Timing != 0.0 {
+		merged.Timing = c.Timing
+	}
+	if c.Suppress {
+		merged.Suppress = true
+	}
+
+	return &merged
+}
+
+// the stream names in after which weren't in before
+func addedStreams(before, after []string) []string {
+	known := make(map[string]bool)
+	for _, k := range before {
+		known[k] = true
+	}
+
+	var added []string
+	for _, k := range after {
+		if !known[k] {
+			added = append(added, k)
+		}
+	}
+
+	return added
+}
+
+// the seedlink selectors needed to request the configured streams, keyed by network and station
+func (r *registry) selection() map[string]string {
+	r.Lock()
+	defer r.Unlock()
+
+	selectors := make(map[string][]string)
+	for k := range r.streams {
+		// expect NN_SSS_LL_CCC
+		parts := strings.Split(k, "_")
+		if len(parts) != 4 {
+			continue
+		}
+		station := parts[0] + "_" + parts[1]
+		selectors[station] = append(selectors[station], parts[2]+parts[3])
+	}
+
+	selection := make(map[string]string)
+	for k, v := range selectors {
+		sort.Strings(v)
+		selection[k] = strings.Join(v, " ")
+	}
+
+	return selection
+}
+
+// check for configuration file changes, calling reload when needed
+func watchConfig(config string, interval time.Duration, reload func()) {
+	var modified time.Time
+	if info, err := os.Stat(config); err == nil {
+		modified = info.ModTime()
+	}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(config)
+		if err != nil {
+			log.Printf("unable to check config file: %s\n", err)
+			continue
+		}
+		if !info.ModTime().After(modified) {
+			continue
+		}
+		modified = info.ModTime()
+
+		log.Printf("config file changed: %s\n", config)
+		reload()
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"github.com/GeoNet/impact"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryReload(t *testing.T) {
+	config := testConfigFile(t, `{
+		"NZ_WEL_10_HHZ": {"Name": "Wellington", "Rate": 100, "Gain": 1000, "Q": 0.98},
+		"NZ_TAU_10_HHZ": {"Name": "Taupo", "Rate": 100, "Gain": 1000, "Q": 0.98}
+	}`)
+	defer os.Remove(config)
+
+	reg, err := newRegistry(config, 10*time.Minute, 2, streamDefaults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := reg.names(); !reflect.DeepEqual(names, []string{"NZ_TAU_10_HHZ", "NZ_WEL_10_HHZ"}) {
+		t.Errorf("unexpected streams: %v", names)
+	}
+
+	if err := ioutil.WriteFile(config, []byte(`{
+		"NZ_WEL_10_HHZ": {"Name": "Wellington", "Rate": 100, "Gain": 1000, "Q": 0.98},
+		"NZ_WEL_20_HNZ": {"Name": "Wellington", "Rate": 200, "Gain": 1000, "Q": 0.98}
+	}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := reg.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"NZ_TAU_10_HHZ"}) {
+		t.Errorf("unexpected removed streams: %v", removed)
+	}
+	if names := reg.names(); !reflect.DeepEqual(names, []string{"NZ_WEL_10_HHZ", "NZ_WEL_20_HNZ"}) {
+		t.Errorf("unexpected streams: %v", names)
+	}
+}
+
+func TestAddedStreams(t *testing.T) {
+	var tests = []struct {
+		before []string
+		after  []string
+		added  []string
+	}{
+		{[]string{"NZ_WEL_10_HHZ"}, []string{"NZ_WEL_10_HHZ"}, nil},
+		{[]string{"NZ_WEL_10_HHZ", "NZ_TAU_10_HHZ"}, []string{"NZ_WEL_10_HHZ"}, nil},
+		{[]string{"NZ_WEL_10_HHZ"}, []string{"NZ_TAU_10_HHZ", "NZ_WEL_10_HHZ", "NZ_WEL_20_HNZ"}, []string{"NZ_TAU_10_HHZ", "NZ_WEL_20_HNZ"}},
+		{nil, []string{"NZ_WEL_10_HHZ"}, []string{"NZ_WEL_10_HHZ"}},
+	}
+
+	for i, v := range tests {
+		if added := addedStreams(v.before, v.after); !reflect.DeepEqual(added, v.added) {
+			t.Errorf("%d: unexpected added streams: %v", i, added)
+		}
+	}
+}
+
+func TestRegistryDetector(t *testing.T) {
+	var tests = []struct {
+		detector string
+		ok       bool
+	}{
+		{`{"Short": 1, "Long": 30, "On": 4, "Off": 1.5}`, true},
+		// on and off missing, it would trigger and never release
+		{`{"Short": 1, "Long": 30}`, false},
+		// otherwise silently ignored
+		{`{"Short": 30, "Long": 1, "On": 4, "Off": 1.5}`, false},
+	}
+
+	for _, v := range tests {
+		config := testConfigFile(t, `{"NZ_WEL_10_HHZ": {"Name": "Wellington", "Rate": 100, "Gain": 1000, "Q": 0.98, "Detector": `+v.detector+`}}`)
+		defer os.Remove(config)
+
+		if _, err := newRegistry(config, 10*time.Minute, 2, streamDefaults{}); (err == nil) != v.ok {
+			t.Errorf("%s: unexpected error result: %v", v.detector, err)
+		}
+	}
+
+	// a reload keeps the running streams
+	config := testConfigFile(t, `{"NZ_WEL_10_HHZ": {"Name": "Wellington", "Rate": 100, "Gain": 1000, "Q": 0.98}}`)
+	defer os.Remove(config)
+
+	reg, err := newRegistry(config, 10*time.Minute, 2, streamDefaults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(config, []byte(`{"NZ_WEL_10_HHZ": {"Name": "Wellington", "Rate": 100, "Gain": 1000, "Q": 0.98, "Detector": {"Short": 1, "Long": 30}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.reload(); err == nil {
+		t.Error("expected an error for an invalid detector on reload")
+	}
+	if reg.streams["NZ_WEL_10_HHZ"].Detector != nil {
+		t.Error("unexpected detector after a refused reload")
+	}
+}
+
+func TestMergeChecks(t *testing.T) {
+	defaults := &impact.Checks{Flat: 10.0, Timing: 50.0, Suppress: true}
+
+	var tests = []struct {
+		checks *impact.Checks
+		merged *impact.Checks
+	}{
+		{nil, defaults},
+		// only flat given, the clock quality threshold and suppression are kept
+		{&impact.Checks{Flat: 5.0}, &impact.Checks{Flat: 5.0, Timing: 50.0, Suppress: true}},
+		{&impact.Checks{Spike: 20.0, Timing: 80.0}, &impact.Checks{Flat: 10.0, Spike: 20.0, Timing: 80.0, Suppress: true}},
+	}
+
+	for i, v := range tests {
+		if merged := mergeChecks(v.checks, defaults); !reflect.DeepEqual(merged, v.merged) {
+			t.Errorf("%d: unexpected checks: %+v", i, merged)
+		}
+	}
+
+	if merged := mergeChecks(&impact.Checks{Flat: 5.0}, nil); merged.Flat != 5.0 || merged.Timing != 0.0 {
+		t.Errorf("unexpected checks without defaults: %+v", merged)
+	}
+	if defaults.Flat != 10.0 {
+		t.Errorf("defaults changed: %+v", defaults)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"github.com/GeoNet/slink"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// a restartable seedlink connection
+type seedlink struct {
+	sync.Mutex
+
+	server    string // seedlink server address
+	netdly    int    // network delay
+	netto     int    // network timeout
+	keepalive int    // keep-alive interval
+
+	streams   string // stream list, used without a selection
+	selectors string // default stream list selectors
+	statefile string // stream state file
+	resume    string // a temporary stream state file to carry over a reconnect without a state file
+
+	slconn   *slink.SLCD // the current connection
+	stopping bool        // shutting down
+	restart  bool        // a new connection is needed
+}
+
+// prepare a new connection, requesting either the selection or the configured stream list, a
+// nil connection is returned if shutting down.
+func (s *seedlink) connect(selection map[string]string) (*slink.SLCD, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stopping {
+		if s.resume != "" {
+			os.Remove(s.resume)
+			s.resume = ""
+		}
+		return nil, nil
+	}
+	s.restart = false
+
+	// initial seedlink handle
+	slconn := slink.NewSLCD()
+
+	// seedlink settings
+	slconn.SetNetDly(s.netdly)
+	slconn.SetNetTo(s.netto)
+	slconn.SetKeepAlive(s.keepalive)
+
+	// conection
+	slconn.SetSLAddr(s.server)
+
+	// configure streams selectors to recover
+	if selection != nil {
+		for k, v := range selection {
+			parts := strings.SplitN(k, "_", 2)
+			if slconn.AddStream(parts[0], parts[1], v, -1, "") < 0 {
+				slink.FreeSLCD(slconn)
+				return nil, fmt.Errorf("unable to add stream: %s [%s]", k, v)
+			}
+		}
+	} else {
+		if _, err := slconn.ParseStreamList(s.streams, s.selectors); err != nil {
+			slink.FreeSLCD(slconn)
+			return nil, err
+		}
+	}
+
+	// carry on from where we left off
+	if s.statefile != "" {
+		if slconn.RecoverState(s.statefile) < 0 {
+			log.Printf("unable to recover seedlink state: %s\n", s.statefile)
+		}
+	} else if s.resume != "" {
+		if slconn.RecoverState(s.resume) < 0 {
+			log.Printf("unable to recover seedlink state: %s\n", s.resume)
+		}
+		os.Remove(s.resume)
+		s.resume = ""
+	}
+
+	s.slconn = slconn
+
+	return slconn, nil
+}
+
+// save the stream state and release the connection
+func (s *seedlink) close(slconn *slink.SLCD) {
+	s.Lock()
+	defer s.Unlock()
+
+	// keep track of where we were
+	if s.statefile != "" {
+		if slconn.SaveState(s.statefile) < 0 {
+			log.Printf("unable to save seedlink state: %s\n", s.statefile)
+		}
+	} else if s.restart && !s.stopping {
+		// otherwise a new connection would start at real time, leaving a break in every stream
+		if err := s.saveResume(slconn); err != nil {
+			log.Printf("unable to save seedlink state for reconnecting: %s\n", err)
+		}
+	}
+
+	slconn.Disconnect()
+	slink.FreeSLCD(slconn)
+
+	s.slconn = nil
+}
+
+// save the stream state to a temporary file for the next connection
+func (s *seedlink) saveResume(slconn *slink.SLCD) error {
+	f, err := ioutil.TempFile("", "slimpact-state")
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	if slconn.SaveState(f.Name()) < 0 {
+		os.Remove(f.Name())
+		return fmt.Errorf("unable to save state: %s", f.Name())
+	}
+	s.resume = f.Name()
+
+	return nil
+}
+
+// stop collecting data for good
+func (s *seedlink) terminate() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.stopping = true
+	if s.slconn != nil {
+		s.slconn.Terminate()
+	}
+}
+
+// stop the current connection so a new one can be made
+func (s *seedlink) reconnect() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.restart = true
+	if s.slconn != nil {
+		s.slconn.Terminate()
+	}
+}
+
+// whether a new connection is needed
+func (s *seedlink) restarting() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.restart && !s.stopping
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"github.com/crowdmob/goamz/aws"
+	"github.com/crowdmob/goamz/sns"
+	"github.com/crowdmob/goamz/sqs"
+	"log"
+	"time"
+)
+
+// somewhere to send messages
+type sink interface {
+	// send a message body along with its routing attributes
+	Send(body string, attributes map[string]string) error
+	// sink description for logging
+	String() string
+}
+
+// send messages to an SQS queue
+type queueSink struct {
+	queue *sqs.Queue
+}
+
+// find the amazon region, an unknown region is only allowed with a custom queue endpoint
+func amazonRegion(name, endpoint string, topic bool) (aws.Region, error) {
+	if r, ok := aws.Regions[name]; ok {
+		return r, nil
+	}
+	if endpoint == "" {
+		return aws.Region{}, fmt.Errorf("unknown amazon region: %s", name)
+	}
+	// the endpoint is only used for SQS, topics would have nowhere to publish to
+	if topic {
+		return aws.Region{}, fmt.Errorf("unknown amazon region for a topic: %s", name)
+	}
+	// still sign requests for the region
+	return aws.Region{Name: name}, nil
+}
+
+// find an SQS queue, optionally via a custom endpoint
+func newQueueSink(credentials *aws.Credentials, region aws.Region, endpoint, queue string) (*queueSink, error) {
+	S, err := sqs.NewWithCredentials(credentials, region)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint != "" {
+		if err := S.SetEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+	}
+	Q, err := S.GetQueue(queue)
+	if err != nil {
+		return nil, err
+	}
+	return &queueSink{queue: Q}, nil
+}
+
+func (q *queueSink) Send(body string, attributes map[string]string) error {
+	_, err := q.queue.SendMessageWithAttributes(body, attributes)
+	return err
+}
+
+func (q *queueSink) String() string {
+	return fmt.Sprintf("queue %s", q.queue.Url)
+}
+
+// publish messages to an SNS topic
+type topicSink struct {
+	sns   *sns.SNS
+	topic string
+}
+
+func (t *topicSink) Send(body string, attributes map[string]string) error {
+	// numeric attributes allow subscription filters such as an MMI of at least 5
+	typed := make(map[string]sns.MessageAttribute)
+	for k, v := range attributes {
+		if numericAttributes[k] {
+			typed[k] = sns.MessageAttribute{DataType: "Number", Value: v}
+		} else {
+			typed[k] = sns.MessageAttribute{DataType: "String", Value: v}
+		}
+	}
+	_, err := t.sns.PublishWithTypedAttributes(t.topic, body, typed)
+	return err
+}
+
+func (t *topicSink) String() string {
+	return fmt.Sprintf("topic %s", t.topic)
+}
+
+// try sending a message, waiting between any resends, returns the number of attempts made
+func deliver(s sink, body string, attributes map[string]string, resends int, wait time.Duration) (int, error) {
+	var err error
+	for n := 0; n < resends; n++ {
+		if err = s.Send(body, attributes); err == nil {
+			return n + 1, nil
+		}
+		log.Printf("unable to send message to %s [#%d/%d]: %s\n", s, n+1, resends, err)
+		if n+1 < resends {
+			log.Printf("sleeping %s\n", wait)
+			time.Sleep(wait)
+		}
+	}
+	return resends, err
+}
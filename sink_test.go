@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"github.com/crowdmob/goamz/aws"
+	"github.com/crowdmob/goamz/sqs"
+	"github.com/crowdmob/goamz/sqs/sqstest"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// a sink which fails a given number of times
+type failingSink struct {
+	failures int
+	attempts int
+}
+
+func (f *failingSink) Send(body string, attributes map[string]string) error {
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (f *failingSink) String() string {
+	return "failing"
+}
+
+func TestDeliver(t *testing.T) {
+	var tests = []struct {
+		failures int
+		attempts int
+		ok       bool
+	}{
+		{0, 1, true},
+		{2, 3, true},
+		{3, 3, false},
+	}
+
+	for i, x := range tests {
+		s := &failingSink{failures: x.failures}
+		n, err := deliver(s, "{}", nil, 3, time.Millisecond)
+		if n != x.attempts || s.attempts != x.attempts {
+			t.Errorf("%d: invalid attempts: %d (found) != %d (expected)", i, n, x.attempts)
+		}
+		if (err == nil) != x.ok {
+			t.Errorf("%d: unexpected result: %v", i, err)
+		}
+	}
+}
+
+func TestDeliverNoFinalWait(t *testing.T) {
+	start := time.Now()
+	if _, err := deliver(&failingSink{failures: 1}, "{}", nil, 1, time.Second); err == nil {
+		t.Fatal("expected a delivery error")
+	}
+	if time.Since(start) >= time.Second {
+		t.Error("waited after the final attempt")
+	}
+}
+
+func TestAmazonRegion(t *testing.T) {
+	var tests = []struct {
+		region   string
+		endpoint string
+		topic    bool
+		ok       bool
+	}{
+		{"ap-southeast-2", "", true, true},
+		{"nz-north-1", "", false, false},
+		{"nz-north-1", "http://localhost:9324", false, true},
+		{"nz-north-1", "http://localhost:9324", true, false},
+	}
+
+	for _, v := range tests {
+		r, err := amazonRegion(v.region, v.endpoint, v.topic)
+		if (err == nil) != v.ok {
+			t.Errorf("%s: unexpected error result: %v", v.region, err)
+			continue
+		}
+		if err == nil && r.Name != v.region {
+			t.Errorf("%s: unexpected region name: %s", v.region, r.Name)
+		}
+	}
+}
+
+func TestQueueSinkEndpoint(t *testing.T) {
+	// an unknown region relies on the custom endpoint
+	R, err := amazonRegion("nz-north-1", "http://localhost", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := sqstest.NewFake(R.Name)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	C, err := aws.NewCredentials("abc", "123", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	S, err := sqs.NewWithCredentials(C, R)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := S.SetEndpoint(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := S.CreateQueue("impact"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newQueueSink(C, R, server.URL, "missing"); err == nil {
+		t.Error("expected an error for a missing queue")
+	}
+
+	q, err := newQueueSink(C, R, server.URL, "impact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Send(`{"MMI":3}`, map[string]string{"MMI": "3", "source": "NZ.WEL"}); err != nil {
+		t.Fatalf("unable to send: %s", err)
+	}
+
+	recv, err := q.queue.ReceiveMessage(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recv.Messages) != 1 || recv.Messages[0].Body != `{"MMI":3}` {
+		t.Errorf("unexpected messages: %+v", recv.Messages)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/GeoNet/impact"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+// the saved noise state of the running streams
+type snapshotFile struct {
+	Saved   time.Time                  `json:"saved"`
+	Streams map[string]impact.Snapshot `json:"streams"`
+}
+
+// write the noise state of all the streams, replacing any previous snapshot
+func (r *registry) save(path string) error {
+	// one writer at a time, so the temporary file isn't shared and the latest snapshot wins
+	r.saving.Lock()
+	defer r.saving.Unlock()
+
+	r.Lock()
+	snapshot := snapshotFile{Saved: time.Now(), Streams: make(map[string]impact.Snapshot)}
+	for k, s := range r.streams {
+		snapshot.Streams[k] = s.Snapshot()
+	}
+	r.Unlock()
+
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// avoid leaving a partial file
+	if err := ioutil.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// recover the noise state of any known streams, snapshots older than age are ignored
+func (r *registry) restore(path string, age time.Duration) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var snapshot snapshotFile
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return err
+	}
+
+	if age > 0 && time.Since(snapshot.Saved) > age {
+		log.Printf("ignoring stale noise snapshot: %s [%s]\n", path, snapshot.Saved)
+		return nil
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for k, v := range snapshot.Streams {
+		if s, ok := r.streams[k]; ok {
+			s.Restore(v)
+		}
+	}
+
+	return nil
+}
+
+// periodically save the noise state until stopped
+func (r *registry) snapshots(path string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.save(path); err != nil {
+				log.Printf("unable to save noise snapshot: %s\n", err)
+			}
+		}
+	}
+}
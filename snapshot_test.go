@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/GeoNet/impact"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "impact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.json")
+
+	r := &registry{streams: map[string]*impact.Stream{
+		"NZ_WEL_10_HHZ": {Name: "test", Rate: 100.0, Gain: 1000.0, Q: 0.98},
+	}}
+	r.streams["NZ_WEL_10_HHZ"].Restore(impact.Snapshot{MMI: 4, Jailed: true})
+
+	// as from the snapshot ticker, the admin handler and a shutdown
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.save(path); err != nil {
+				t.Errorf("unable to save snapshot: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected no temporary snapshot file: %v", err)
+	}
+
+	restored := &registry{streams: map[string]*impact.Stream{
+		"NZ_WEL_10_HHZ": {Name: "test", Rate: 100.0, Gain: 1000.0, Q: 0.98},
+	}}
+	if err := restored.restore(path, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if s := restored.streams["NZ_WEL_10_HHZ"].Snapshot(); s.MMI != 4 || !s.Jailed {
+		t.Errorf("unexpected restored snapshot: %+v", s)
+	}
+}
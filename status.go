@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/GeoNet/impact"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// running process state, shared with the http status handlers
+type status struct {
+	sync.Mutex
+
+	age time.Duration // how old the last packet can be and still be healthy
+
+	connected bool                    // seedlink connection state
+	packet    time.Time               // last packet received
+	sinks     map[string]error        // last delivery result for each sink
+	streams   map[string]streamStatus // per-stream state
+}
+
+// the state of a single stream
+type streamStatus struct {
+	Packet  time.Time `json:"packet"`  // last packet received
+	Latency float64   `json:"latency"` // delay between the record end time and it being received
+	impact.Status
+}
+
+// the overall process health
+type health struct {
+	Healthy   bool              `json:"healthy"`
+	Connected bool              `json:"connected"`
+	Packet    time.Time         `json:"packet"`
+	Age       float64           `json:"age"`
+	Sinks     map[string]string `json:"sinks"`
+}
+
+func newStatus(age time.Duration) *status {
+	return &status{
+		age:     age,
+		sinks:   make(map[string]error),
+		streams: make(map[string]streamStatus),
+	}
+}
+
+// note the seedlink connection state
+func (s *status) setConnected(connected bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.connected = connected
+}
+
+// note a received packet and the resulting stream state
+func (s *status) setStream(srcname string, state impact.Status) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.packet = time.Now()
+	s.streams[srcname] = streamStatus{Packet: s.packet, Latency: s.packet.Sub(state.Last).Seconds(), Status: state}
+}
+
+// forget a stream which is no longer configured
+func (s *status) removeStream(srcname string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.streams, srcname)
+}
+
+// note the result of delivering a message
+func (s *status) setSink(name string, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.sinks[name] = err
+}
+
+func (s *status) health() health {
+	s.Lock()
+	defer s.Unlock()
+
+	h := health{
+		Healthy:   s.connected && time.Since(s.packet) < s.age,
+		Connected: s.connected,
+		Packet:    s.packet,
+		Age:       time.Since(s.packet).Seconds(),
+		Sinks:     make(map[string]string),
+	}
+	for k, v := range s.sinks {
+		if v != nil {
+			h.Sinks[k] = v.Error()
+			h.Healthy = false
+		} else {
+			h.Sinks[k] = "ok"
+		}
+	}
+
+	return h
+}
+
+func (s *status) snapshot() map[string]streamStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	streams := make(map[string]streamStatus)
+	for k, v := range s.streams {
+		streams[k] = v
+	}
+
+	return streams
+}
+
+func (s *status) handleHealth(w http.ResponseWriter, r *http.Request) {
+	h := s.health()
+	if !h.Healthy {
+		writeJSON(w, http.StatusServiceUnavailable, h)
+		return
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+func (s *status) handleStreams(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.snapshot())
+}
+
+// add the status handlers to an http server
+func (s *status) register(mux *http.ServeMux) {
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/streams", s.handleStreams)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("unable to encode status: %s\n", err)
+	}
+}